@@ -0,0 +1,37 @@
+//go:build tinygo
+// +build tinygo
+
+package battery
+
+import (
+	m "machine"
+)
+
+const adcSamples = 4
+
+// ADC samples the battery through a voltage divider on an analog pin, such as
+// the VBAT pin of the Feather boards which halves the battery voltage
+type ADC struct {
+	adc       m.ADC
+	divider   uint32
+	reference uint32
+}
+
+// NewADC returns a source that reads pin, which is scaled up by divider and
+// by the reference voltage of the ADC in millivolts
+func NewADC(pin m.Pin, divider uint8, reference uint16) *ADC {
+	m.InitADC()
+	adc := m.ADC{Pin: pin}
+	adc.Configure(m.ADCConfig{})
+	return &ADC{adc: adc, divider: uint32(divider), reference: uint32(reference)}
+}
+
+// Millivolts returns the average of a few samples; the value returned by
+// ADC.Get is scaled to 16 bits regardless of the resolution of the ADC
+func (a *ADC) Millivolts() uint16 {
+	var sum uint32
+	for i := 0; i < adcSamples; i++ {
+		sum += uint32(a.adc.Get())
+	}
+	return uint16(sum / adcSamples * a.reference * a.divider >> 16)
+}
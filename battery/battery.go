@@ -0,0 +1,167 @@
+// Package battery samples the voltage of a LiPo battery, converts it to a
+// charge level, and notifies the firmware when the level changes or the
+// battery is running low.
+package battery
+
+import (
+	"time"
+
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+const (
+	DefaultInterval     = 60 * time.Second
+	DefaultLowThreshold = 10
+
+	// lowHysteresis is how far the level has to rise above the threshold
+	// before the battery is no longer considered low
+	lowHysteresis = 5
+)
+
+// Source samples the battery voltage in millivolts
+type Source interface {
+	Millivolts() uint16
+}
+
+// Fake is a source that returns a voltage set by the caller
+type Fake struct {
+	MV uint16
+}
+
+func (f *Fake) Millivolts() uint16 {
+	return f.MV
+}
+
+// curve is the discharge curve of a typical 3.7V LiPo cell under light load,
+// as millivolts for every 5 percent from empty to full
+var curve = [...]uint16{
+	3270, 3610, 3690, 3710, 3730, 3750, 3770, 3790, 3800, 3820, 3840,
+	3850, 3870, 3910, 3950, 3980, 4020, 4080, 4110, 4150, 4200,
+}
+
+// Percent returns the charge level of a LiPo cell at the specified voltage,
+// interpolating linearly between the points of the discharge curve
+func Percent(mv uint16) uint8 {
+	if mv <= curve[0] {
+		return 0
+	}
+	for i := 1; i < len(curve); i++ {
+		if mv < curve[i] {
+			lo, hi := uint32(curve[i-1]), uint32(curve[i])
+			return uint8((i-1)*5) + uint8((uint32(mv)-lo)*5/(hi-lo))
+		}
+	}
+	return 100
+}
+
+// Monitor samples a source at a fixed interval
+type Monitor struct {
+	source   Source
+	clock    timer.Clock
+	interval time.Duration
+	timer    timer.Timer
+	sampled  bool
+
+	mv    uint16
+	level uint8
+
+	threshold uint8
+	low       bool
+
+	levelHook func(percent uint8)
+	lowHook   func(low bool)
+}
+
+func NewMonitor(source Source) *Monitor {
+	return &Monitor{
+		source:    source,
+		clock:     timer.System,
+		interval:  DefaultInterval,
+		threshold: DefaultLowThreshold,
+	}
+}
+
+func (mon *Monitor) WithClock(clock timer.Clock) *Monitor {
+	mon.clock = clock
+	return mon
+}
+
+// WithInterval sets how often the battery is sampled
+func (mon *Monitor) WithInterval(interval time.Duration) *Monitor {
+	mon.interval = interval
+	return mon
+}
+
+// WithLowThreshold sets the level in percent at or below which the battery is
+// considered low
+func (mon *Monitor) WithLowThreshold(percent uint8) *Monitor {
+	mon.threshold = percent
+	return mon
+}
+
+// WithLevelHook sets a function that is called whenever the level changes,
+// e.g. to report it to the host
+func (mon *Monitor) WithLevelHook(hook func(percent uint8)) *Monitor {
+	mon.levelHook = hook
+	return mon
+}
+
+// WithLowBatteryHook sets a function that is called when the battery becomes
+// low, and again when it is no longer low after being charged
+func (mon *Monitor) WithLowBatteryHook(hook func(low bool)) *Monitor {
+	mon.lowHook = hook
+	return mon
+}
+
+// Millivolts returns the last voltage sampled
+func (mon *Monitor) Millivolts() uint16 {
+	return mon.mv
+}
+
+// Level returns the last charge level in percent
+func (mon *Monitor) Level() uint8 {
+	return mon.level
+}
+
+// Low returns true if the battery is low
+func (mon *Monitor) Low() bool {
+	return mon.low
+}
+
+// Task samples the battery if the interval has elapsed since the last sample;
+// the first call always samples
+func (mon *Monitor) Task() {
+	if mon.sampled && !mon.timer.Expired() {
+		return
+	}
+	mon.timer = timer.New(mon.clock, mon.interval)
+	mon.Sample()
+}
+
+// Sample reads the source immediately and calls the hooks if the level or the
+// low battery state has changed
+func (mon *Monitor) Sample() {
+	mon.mv = mon.source.Millivolts()
+	level := Percent(mon.mv)
+	if level != mon.level || !mon.sampled {
+		mon.level = level
+		if mon.levelHook != nil {
+			mon.levelHook(level)
+		}
+	}
+	mon.sampled = true
+
+	low := mon.low
+	switch {
+	case level <= mon.threshold:
+		low = true
+	case level > mon.threshold+lowHysteresis:
+		low = false
+	}
+	if low != mon.low {
+		mon.low = low
+		if mon.lowHook != nil {
+			mon.lowHook(low)
+		}
+	}
+}
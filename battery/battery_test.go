@@ -0,0 +1,144 @@
+package battery_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/battery"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		mv   uint16
+		want uint8
+	}{
+		// endpoints, and beyond them
+		{0, 0},
+		{3000, 0},
+		{3270, 0},
+		{4200, 100},
+		{5000, 100},
+		// points on the curve
+		{3610, 5},
+		{3840, 50},
+		{4150, 95},
+		// between the points
+		{3440, 2},
+		{3650, 7},
+		{3694, 11},
+		{4199, 99},
+	}
+	for _, tt := range tests {
+		if got := battery.Percent(tt.mv); got != tt.want {
+			t.Errorf("Percent(%d) = %d, want %d", tt.mv, got, tt.want)
+		}
+	}
+	prev := uint8(0)
+	for mv := uint16(3000); mv <= 4300; mv++ {
+		p := battery.Percent(mv)
+		if p < prev {
+			t.Fatalf("Percent(%d) = %d, less than %d at %d mV", mv, p, prev, mv-1)
+		}
+		prev = p
+	}
+}
+
+// millivolts returns the lowest voltage with the specified charge level
+func millivolts(t *testing.T, percent uint8) uint16 {
+	for mv := uint16(3000); mv <= 4300; mv++ {
+		if battery.Percent(mv) == percent {
+			return mv
+		}
+	}
+	t.Fatalf("no voltage for %d%%", percent)
+	return 0
+}
+
+func TestMonitorLow(t *testing.T) {
+	source := &battery.Fake{}
+	var lows []bool
+	mon := battery.NewMonitor(source).WithClock(timer.NewFake()).
+		WithLowBatteryHook(func(low bool) { lows = append(lows, low) })
+	steps := []struct {
+		percent uint8
+		low     bool
+	}{
+		{50, false},
+		{11, false},
+		{10, true}, // at the threshold
+		{15, true},
+		{12, true},
+		{15, true}, // not above the threshold plus the hysteresis
+		{16, false},
+		{11, false},
+		{9, true},
+		{100, false},
+	}
+	var want []bool
+	for i, step := range steps {
+		source.MV = millivolts(t, step.percent)
+		mon.Sample()
+		if mon.Low() != step.low {
+			t.Errorf("step %d: %d%%: got low %t, want %t", i, step.percent, mon.Low(), step.low)
+		}
+		if i > 0 && step.low != steps[i-1].low {
+			want = append(want, step.low)
+		}
+	}
+	if fmt.Sprint(lows) != fmt.Sprint(want) {
+		t.Errorf("got low battery hooks %v, want %v", lows, want)
+	}
+}
+
+func TestMonitorThreshold(t *testing.T) {
+	source := &battery.Fake{MV: millivolts(t, 20)}
+	mon := battery.NewMonitor(source).WithLowThreshold(20)
+	mon.Sample()
+	if !mon.Low() {
+		t.Errorf("20%% is not low with a threshold of 20%%")
+	}
+	source.MV = millivolts(t, 25)
+	mon.Sample()
+	if !mon.Low() {
+		t.Errorf("25%% is not low after 20%%")
+	}
+	source.MV = millivolts(t, 26)
+	mon.Sample()
+	if mon.Low() {
+		t.Errorf("26%% is still low")
+	}
+}
+
+func TestMonitorTask(t *testing.T) {
+	source := &battery.Fake{MV: 4200}
+	clock := timer.NewFake()
+	var levels []uint8
+	mon := battery.NewMonitor(source).WithClock(clock).WithInterval(10 * time.Second).
+		WithLevelHook(func(percent uint8) { levels = append(levels, percent) })
+
+	// the first call always samples and reports the level
+	mon.Task()
+	if mon.Millivolts() != 4200 || mon.Level() != 100 {
+		t.Errorf("got %d mV, %d%%", mon.Millivolts(), mon.Level())
+	}
+	source.MV = 3840
+	clock.Advance(9 * time.Second)
+	mon.Task()
+	if mon.Level() != 100 {
+		t.Errorf("sampled before the interval: %d%%", mon.Level())
+	}
+	clock.Advance(time.Second)
+	mon.Task()
+	if mon.Level() != 50 {
+		t.Errorf("not sampled after the interval: %d%%", mon.Level())
+	}
+	// the same level is not reported again
+	source.MV = 3841
+	clock.Advance(10 * time.Second)
+	mon.Task()
+	if fmt.Sprint(levels) != fmt.Sprint([]uint8{100, 50}) {
+		t.Errorf("got level hooks %v", levels)
+	}
+}
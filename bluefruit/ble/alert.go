@@ -0,0 +1,80 @@
+package ble
+
+import (
+	"time"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+// SDEPError is returned when the module answers a command with an SDEP error
+// message; its value is the error ID from the message.
+type SDEPError uint16
+
+const (
+	SDEPErrInvalidCommand SDEPError = 0x0001
+	SDEPErrInvalidPayload SDEPError = 0x0003
+)
+
+func (err SDEPError) Error() string {
+	switch err {
+	case SDEPErrInvalidCommand:
+		return "ble: sdep error: invalid command ID"
+	case SDEPErrInvalidPayload:
+		return "ble: sdep error: invalid payload"
+	}
+	return "ble: sdep error: 0x" + hexByte(byte(err>>8)) + hexByte(byte(err))
+}
+
+// Alert is an unsolicited message from the module.  Each alert packet is
+// delivered separately, and Payload is only valid until the handler returns.
+type Alert struct {
+	ID      uint16
+	Payload []byte
+}
+
+// SetAlertHandler sets a function to call with the alerts received while
+// waiting for a response or in Poll; alerts are dropped if it is nil
+func (dev *SPIFriend) SetAlertHandler(handler func(alert Alert)) {
+	dev.onAlert = handler
+}
+
+// Poll reads the packets that the module has pending outside of a command,
+// passing alerts to the alert handler and dropping anything else.  It gives up
+// with ErrReadTimeout if the module keeps IRQ raised for as long as a command
+// would be waited for.
+func (dev *SPIFriend) Poll() error {
+	if !dev.bus.IRQ() {
+		return nil
+	}
+	dev.bus.Select(true)
+	defer dev.bus.Select(false)
+	dev.mandatoryDelay()
+	for t := timer.New(dev.clock, 2*time.Second); dev.bus.IRQ(); {
+		if t.Expired() {
+			return ErrReadTimeout
+		}
+		err := dev.readPacket()
+		switch err {
+		case nil:
+		case ErrSlaveDeviceNotReady, ErrSlaveDeviceReadOverflow:
+			dev.resync()
+			continue
+		default:
+			return err
+		}
+		if dev.msg.Header.Type == sdep.MsgTypeAlert {
+			dev.alert()
+		}
+	}
+	return nil
+}
+
+func (dev *SPIFriend) alert() {
+	if dev.verbose {
+		dev.debug("alert: %04X", dev.msg.Header.ID)
+	}
+	if dev.onAlert != nil {
+		dev.onAlert(Alert{ID: dev.msg.Header.ID, Payload: dev.msg.GetPayload()})
+	}
+}
@@ -0,0 +1,299 @@
+package ble_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+	"github.com/bgould/tinygo-model-m/bluefruit/ble/sim"
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+func begin(t *testing.T) (*sim.Module, *ble.SPIFriend, *timer.Fake) {
+	mod := sim.New()
+	clock := timer.NewFake()
+	dev := ble.New(mod)
+	if err := dev.Begin(ble.SPIFriendConfig{Clock: clock}); err != nil {
+		t.Fatal(err)
+	}
+	return mod, dev, clock
+}
+
+// packetCounter counts the command packets written to the module, each of
+// which starts with a single byte transfer of the message type
+type packetCounter struct {
+	*sim.Module
+	packets int
+}
+
+func (c *packetCounter) Transfer(w byte) (byte, error) {
+	if w == sdep.MsgTypeCommand {
+		c.packets++
+	}
+	return c.Module.Transfer(w)
+}
+
+func TestSendATFragmentation(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		body    string
+		packets int
+	}{
+		{"one packet", "ATI", "BLESPIFRIEND", 1},
+		{"exactly one packet", "AT+GAPGETCONN=12", "1", 1},
+		{"one byte over", "AT+GAPGETCONN=123", "1", 2},
+		{"several packets", "AT+GAPDEVNAME=" + strings.Repeat("x", 40), strings.Repeat("y", 100), 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mod := sim.New()
+			bus := &packetCounter{Module: mod}
+			dev := ble.New(bus)
+			if err := dev.Begin(ble.SPIFriendConfig{Clock: timer.NewFake()}); err != nil {
+				t.Fatal(err)
+			}
+			bus.packets = 0
+			mod.RespondOK(tt.command, tt.body)
+			rsp, err := dev.Command(tt.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rsp) != tt.body {
+				t.Errorf("got response %q, want %q", rsp, tt.body)
+			}
+			if cmds := mod.Commands(); len(cmds) != 1 || cmds[0] != tt.command {
+				t.Errorf("module received %q, want %q", cmds, tt.command)
+			}
+			if bus.packets != tt.packets {
+				t.Errorf("sent %d packets, want %d", bus.packets, tt.packets)
+			}
+			if mod.Pending() != 0 {
+				t.Errorf("%d packets left unread", mod.Pending())
+			}
+		})
+	}
+}
+
+func TestSendATRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		notReady int
+		overflow int
+	}{
+		{"not ready", 3, 0},
+		{"read overflow", 0, 2},
+		{"both", 3, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mod, dev, _ := begin(t)
+			mod.RespondOK("AT+BLEGETADDR", "E4:C6:C7:31:95:11")
+			mod.InjectNotReady(tt.notReady)
+			mod.InjectOverflow(tt.overflow)
+			addr, err := dev.Address()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := addr.String(); s != "E4:C6:C7:31:95:11" {
+				t.Errorf("got address %s", s)
+			}
+			if cmds := mod.Commands(); len(cmds) != 1 {
+				t.Errorf("module received %q, want the command once", cmds)
+			}
+		})
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	mod, dev, _ := begin(t)
+	mod.Respond("AT+FAIL", "partial\r\nERROR\r\n")
+	mod.RespondError("AT+BAD", uint16(ble.SDEPErrInvalidCommand))
+
+	body, err := dev.Command("AT+FAIL")
+	var atErr *ble.ATError
+	if !errors.As(err, &atErr) {
+		t.Fatalf("got error %v, want an *ATError", err)
+	}
+	if atErr.Command != "AT+FAIL" || atErr.Status != "ERROR" || string(body) != "partial" {
+		t.Errorf("got %+v with body %q", atErr, body)
+	}
+
+	// commands without a scripted response are answered with ERROR
+	if _, err := dev.Command("AT+UNKNOWN"); !errors.As(err, &atErr) || atErr.Command != "AT+UNKNOWN" {
+		t.Errorf("got error %v, want an *ATError", err)
+	}
+
+	if _, err := dev.Command("AT+BAD"); err != ble.SDEPErrInvalidCommand {
+		t.Errorf("got error %v, want %v", err, ble.SDEPErrInvalidCommand)
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	mod, dev, _ := begin(t)
+	big := strings.Repeat("a", ble.ResponseBufferSize+500)
+	mod.RespondOK("AT+BIG", big)
+	mod.RespondOK("ATI", "BLESPIFRIEND")
+
+	rsp, err := dev.Command("AT+BIG")
+	if err != ble.ErrResponseTooLarge {
+		t.Fatalf("got error %v, want %v", err, ble.ErrResponseTooLarge)
+	}
+	if string(rsp) != big[:ble.ResponseBufferSize] {
+		t.Errorf("got %d bytes of the response, want the first %d", len(rsp), ble.ResponseBufferSize)
+	}
+	// the rest of the response has been read, so the next command works
+	if mod.Pending() != 0 {
+		t.Errorf("%d packets left unread", mod.Pending())
+	}
+	if rsp, err := dev.Command("ATI"); err != nil || string(rsp) != "BLESPIFRIEND" {
+		t.Errorf("got %q, %v after a truncated response", rsp, err)
+	}
+
+	if _, err := dev.SendAT("AT" + strings.Repeat("x", ble.CommandBufferSize)); err != ble.ErrCommandTooLarge {
+		t.Errorf("got error %v, want %v", err, ble.ErrCommandTooLarge)
+	}
+}
+
+func TestClockDelays(t *testing.T) {
+	// Begin resets the module, pulsing the reset line for 10ms and then
+	// waiting a second for it to boot
+	mod, dev, clock := begin(t)
+	const boot = time.Second + 10*time.Millisecond
+	if now := clock.Now(); now < boot || now > boot+time.Millisecond {
+		t.Fatalf("Begin took %v, want %v", now, boot)
+	}
+	clock.Set(0)
+	if err := dev.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if now := clock.Now(); now < boot || now > boot+time.Millisecond {
+		t.Fatalf("Reset took %v, want %v", now, boot)
+	}
+	// a command waits 10us before selecting the module and another 100us
+	// before reading the response
+	clock.Set(0)
+	mod.RespondOK("ATI", "BLESPIFRIEND")
+	if _, err := dev.Command("ATI"); err != nil {
+		t.Fatal(err)
+	}
+	if now := clock.Now(); now < 110*time.Microsecond || now > time.Millisecond {
+		t.Fatalf("command took %v", now)
+	}
+}
+
+// alertRecorder collects the alerts passed to the alert handler
+type alertRecorder []string
+
+func (r *alertRecorder) handle(alert ble.Alert) {
+	*r = append(*r, fmt.Sprintf("%04X %s", alert.ID, alert.Payload))
+}
+
+func TestAlertDuringCommand(t *testing.T) {
+	mod, dev, _ := begin(t)
+	var alerts alertRecorder
+	dev.SetAlertHandler(alerts.handle)
+	mod.Queue(sdep.MsgTypeAlert, 0x0001, []byte("connected"))
+	mod.RespondOK("ATI", "BLESPIFRIEND")
+	rsp, err := dev.Command("ATI")
+	if err != nil || string(rsp) != "BLESPIFRIEND" {
+		t.Fatalf("got %q, %v", rsp, err)
+	}
+	if want := []string{"0001 connected"}; fmt.Sprint(alerts) != fmt.Sprint(want) {
+		t.Fatalf("got alerts %q, want %q", alerts, want)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	mod, dev, clock := begin(t)
+	var alerts alertRecorder
+	dev.SetAlertHandler(alerts.handle)
+	if err := dev.Poll(); err != nil || len(alerts) != 0 {
+		t.Fatalf("poll with nothing pending: %q, %v", alerts, err)
+	}
+
+	// the response is dropped, and the alert split over two packets is
+	// delivered one packet at a time
+	mod.Queue(sdep.MsgTypeAlert, 0x0001, []byte("a"))
+	mod.Queue(sdep.MsgTypeResponse, sdep.CmdTypeATWrapper, []byte("OK\r\n"))
+	mod.Queue(sdep.MsgTypeAlert, 0x0002, []byte(strings.Repeat("b", 20)))
+	mod.InjectOverflow(2)
+	if err := dev.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0001 a", "0002 " + strings.Repeat("b", 16), "0002 bbbb"}
+	if fmt.Sprint(alerts) != fmt.Sprint(want) {
+		t.Fatalf("got alerts %q, want %q", alerts, want)
+	}
+	if mod.Pending() != 0 {
+		t.Fatalf("%d packets left unread", mod.Pending())
+	}
+
+	// a module that never becomes ready gives up after 2 seconds
+	mod.Queue(sdep.MsgTypeAlert, 0x0003, nil)
+	mod.InjectOverflow(1 << 30)
+	clock.Set(0)
+	if err := dev.Poll(); err != ble.ErrReadTimeout {
+		t.Fatalf("got error %v, want %v", err, ble.ErrReadTimeout)
+	}
+	if now := clock.Now(); now < 2*time.Second || now > 2*time.Second+time.Millisecond {
+		t.Fatalf("gave up after %v", now)
+	}
+}
+
+func TestSDEPErrorFailsFast(t *testing.T) {
+	mod, dev, clock := begin(t)
+	mod.RespondError("AT+BAD", uint16(ble.SDEPErrInvalidCommand))
+	clock.Set(0)
+	_, err := dev.Command("AT+BAD")
+	if err == nil || err.Error() != "ble: sdep error: invalid command ID" {
+		t.Fatalf("got error %v", err)
+	}
+	if now := clock.Now(); now > time.Millisecond {
+		t.Fatalf("took %v to return the error", now)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	seen := make(map[string]ble.Error)
+	for _, err := range []ble.Error{
+		ble.ErrNone,
+		ble.ErrPacketTooLarge,
+		ble.ErrResponseTooLarge,
+		ble.ErrCommandTooLarge,
+		ble.ErrNoData,
+		ble.ErrReadTimeout,
+		ble.ErrWriteTimeout,
+		ble.ErrUnexpectedCommand,
+		ble.ErrUnexpectedByte,
+		ble.ErrSlaveDeviceNotReady,
+		ble.ErrSlaveDeviceReadOverflow,
+	} {
+		msg := err.Error()
+		if !strings.HasPrefix(msg, "ble: ") || strings.HasPrefix(msg, "ble: error ") {
+			t.Errorf("error %d has no message: %q", uint32(err), msg)
+		}
+		if other, ok := seen[msg]; ok {
+			t.Errorf("errors %d and %d have the same message %q", uint32(other), uint32(err), msg)
+		}
+		seen[msg] = err
+	}
+}
+
+func TestTransactErrors(t *testing.T) {
+	mod, dev, _ := begin(t)
+	mod.InjectNotReady(25)
+	if _, err := dev.Command("ATI"); err != ble.ErrWriteTimeout {
+		t.Errorf("got error %v, want %v", err, ble.ErrWriteTimeout)
+	}
+
+	mod, dev, _ = begin(t)
+	mod.Queue(sdep.MsgTypeCommand, sdep.CmdTypeATWrapper, nil)
+	if _, err := dev.Command("ATI"); err != ble.ErrUnexpectedCommand {
+		t.Errorf("got error %v, want %v", err, ble.ErrUnexpectedCommand)
+	}
+}
@@ -1,9 +1,7 @@
 package ble
 
 import (
-	"bytes"
 	"fmt"
-	m "machine"
 	"strconv"
 	"time"
 
@@ -14,13 +12,64 @@ import (
 type Error uint32
 
 func (err Error) Error() string {
-	return strconv.Itoa(int(err))
+	switch err {
+	case ErrNone:
+		return "ble: no error"
+	case ErrPacketTooLarge:
+		return "ble: packet too large"
+	case ErrResponseTooLarge:
+		return "ble: response too large"
+	case ErrCommandTooLarge:
+		return "ble: command too large"
+	case ErrNoData:
+		return "ble: no UART data"
+	case ErrReadTimeout:
+		return "ble: read timeout"
+	case ErrWriteTimeout:
+		return "ble: write timeout"
+	case ErrUnexpectedCommand:
+		return "ble: unexpected command message"
+	case ErrUnexpectedByte:
+		return "ble: unexpected byte from module"
+	case ErrSlaveDeviceNotReady:
+		return "ble: module not ready"
+	case ErrSlaveDeviceReadOverflow:
+		return "ble: module read overflow"
+	}
+	return "ble: error " + strconv.Itoa(int(err))
 }
 
 const (
 	ErrNone Error = iota
 	ErrPacketTooLarge
 
+	// ErrResponseTooLarge is returned along with the first
+	// ResponseBufferSize bytes of a response that did not fit in the buffer
+	ErrResponseTooLarge
+
+	// ErrCommandTooLarge is returned for commands longer than
+	// CommandBufferSize
+	ErrCommandTooLarge
+
+	// ErrNoData is returned by UART.Read when no data has been received
+	ErrNoData
+
+	// ErrReadTimeout is returned when the module does not finish sending a
+	// message within 2 seconds
+	ErrReadTimeout
+
+	// ErrWriteTimeout is returned when the module is still not ready to
+	// receive a command after being retried
+	ErrWriteTimeout
+
+	// ErrUnexpectedCommand is returned when the module answers a command with
+	// a command message
+	ErrUnexpectedCommand
+
+	// ErrUnexpectedByte is returned when a packet starts with a byte that is
+	// not a valid message type
+	ErrUnexpectedByte
+
 	ErrSlaveDeviceNotReady     Error = Error(sdep.ErrSlaveDeviceNotReady)
 	ErrSlaveDeviceReadOverflow       = Error(sdep.ErrSlaveDeviceReadOverflow)
 )
@@ -32,43 +81,49 @@ const (
 	DataMode
 
 	ResponseBufferSize = 2048
+	CommandBufferSize  = 256
 )
 
 type SPIFriend struct {
-	bus *m.SPI
-	cs  m.Pin
-	irq m.Pin
-	rst m.Pin
+	bus Transport
 
 	msg     sdep.Message
-	buf     *bytes.Buffer
+	pkt     [sdep.MaxPacketSize]byte
+	cmd     [CommandBufferSize]byte
+	rsp     *sdep.Reassembler
 	mode    Mode
 	verbose bool
+	clock   timer.Clock
+
+	services []*Service
+	onAlert  func(alert Alert)
 }
 
 type SPIFriendConfig struct {
 	Verbose  bool
 	Blocking bool
+	Clock    timer.Clock
 }
 
-func NewSPIFriend(bus *m.SPI, cs m.Pin, irq m.Pin, rst m.Pin) *SPIFriend {
+// New returns a driver for a module connected with the specified transport
+func New(bus Transport) *SPIFriend {
 	return &SPIFriend{
-		bus:  bus,
-		cs:   cs,
-		irq:  irq,
-		rst:  rst,
-		buf:  bytes.NewBuffer(make([]byte, ResponseBufferSize)),
-		mode: CommandMode,
+		bus:   bus,
+		rsp:   sdep.NewReassembler(make([]byte, ResponseBufferSize)),
+		mode:  CommandMode,
+		clock: timer.System,
 	}
 }
 
 func (dev *SPIFriend) Begin(config SPIFriendConfig) (err error) {
 	dev.verbose = config.Verbose
+	if config.Clock != nil {
+		dev.clock = config.Clock
+	}
 
-	dev.irq.Configure(m.PinConfig{Mode: m.PinInput})
-
-	dev.cs.Configure(m.PinConfig{Mode: m.PinOutput})
-	dev.cs.High()
+	if err = dev.bus.Configure(); err != nil {
+		return err
+	}
 
 	return dev.Reset()
 }
@@ -79,12 +134,9 @@ func (dev *SPIFriend) Reset() (err error) {
 	// Bluefruit since user can define but not wiring RST signal
 	err = dev.sendInitializePattern()
 
-	if dev.rst != m.NoPin {
-		dev.rst.Configure(m.PinConfig{Mode: m.PinOutput})
-		dev.rst.High()
-		dev.rst.Low()
-		timer.Wait(10 * time.Millisecond)
-		dev.rst.High()
+	if dev.bus.Reset(true) {
+		dev.clock.Sleep(10 * time.Millisecond)
+		dev.bus.Reset(false)
 		err = nil
 	}
 
@@ -94,7 +146,7 @@ func (dev *SPIFriend) Reset() (err error) {
 	if dev.verbose {
 		dev.debug("waiting 1 second for reset\r")
 	}
-	timer.Wait(1 * time.Second)
+	dev.clock.Sleep(1 * time.Second)
 	if dev.verbose {
 		dev.debug("returning from Begin()")
 	}
@@ -103,89 +155,106 @@ func (dev *SPIFriend) Reset() (err error) {
 
 }
 
+// SendAT sends an AT command and returns the raw response, which is only
+// valid until the next command is sent.  The command is copied into a
+// preallocated buffer, so it must not be longer than CommandBufferSize.
 func (dev *SPIFriend) SendAT(command string) ([]byte, error) {
+	if len(command) > len(dev.cmd) {
+		return nil, ErrCommandTooLarge
+	}
+	n := copy(dev.cmd[:], command)
+	return dev.transact(sdep.CmdTypeATWrapper, dev.cmd[:n])
+}
 
-	defer dev.cs.High()
-	dev.buf.Reset()
+// transact sends payload as a command with the specified ID and waits for the
+// response, which is only valid until the next command is sent.  A response
+// that does not fit in the buffer is read to the end, and what fits is
+// returned with ErrResponseTooLarge.
+func (dev *SPIFriend) transact(id uint16, payload []byte) ([]byte, error) {
 
-	cmd := []byte(command)
-	for i, c, more := 0, len(command), true; i < c; i += sdep.MaxPayloadSize {
-		j := i + sdep.MaxPayloadSize
-		if j >= c {
-			j = c
-			more = false
-		}
-		err := dev.sendPacket(sdep.CmdTypeATWrapper, cmd[i:j], more)
-		if err != nil {
+	defer dev.bus.Select(false)
+	dev.rsp.Reset()
+
+	for rest := payload; ; {
+		rest = sdep.Fragment(&dev.msg, sdep.MsgTypeCommand, id, rest)
+		if err := dev.sendPacket(); err != nil {
 			return nil, err
 		}
+		if len(rest) == 0 {
+			break
+		}
 	}
 
-	delay()
-	dev.cs.Low()
-	mandatoryDelay()
+	dev.delay()
+	dev.bus.Select(true)
+	dev.mandatoryDelay()
 
-	t := timer.New(2 * time.Second)
+	t := timer.New(dev.clock, 2*time.Second)
+	truncated := false
 
 	for !t.Expired() {
-		if !dev.irq.Get() {
+		if !dev.bus.IRQ() {
+			dev.delay()
 			continue
 		}
 		err := dev.readPacket()
-		if err != nil {
-			/*
-				if e, ok := err.(Error); ok {
-					if uint32(e) == uint32(ErrSlaveDeviceNotReady) ||
-						uint32(e) == uint32(ErrSlaveDeviceReadOverflow) {
-			*/
-			dev.cs.High()
-			delay()
-			dev.cs.Low()
-			mandatoryDelay()
+		switch err {
+		case nil:
+		case ErrSlaveDeviceNotReady, ErrSlaveDeviceReadOverflow:
+			dev.resync()
 			continue
-			/*
-					}
-				}
-				return nil, err
-			*/
-		}
-		newlen := dev.buf.Len() + int(dev.msg.Header.GetLength())
-		if newlen > ResponseBufferSize {
-			panic(fmt.Sprintf("response too large for buffer: %s", newlen))
+		default:
+			return nil, err
 		}
-		dev.buf.Write(dev.msg.GetPayload())
-		//payload := dev.msg.GetPayload()
-		/*
-			if dev.verbose {
-				dev.debug("AT response: %s", string(payload))
-			}
-		*/
-		if dev.msg.Header.HasMoreData() {
+		switch dev.msg.Header.Type {
+		case sdep.MsgTypeAlert:
+			dev.alert()
 			continue
-		} else {
-			return dev.buf.Bytes(), nil
+		case sdep.MsgTypeError:
+			return nil, SDEPError(dev.msg.Header.ID)
+		case sdep.MsgTypeCommand:
+			return nil, ErrUnexpectedCommand
+		}
+		done, err := dev.rsp.Add(&dev.msg)
+		switch err {
+		case nil:
+		case sdep.ErrMessageTooLarge:
+			truncated = true
+		default:
+			return nil, err
+		}
+		if done && truncated {
+			return dev.rsp.Bytes(), ErrResponseTooLarge
+		}
+		if done {
+			return dev.rsp.Bytes(), nil
 		}
 	}
-	return nil, fmt.Errorf("read timeout")
+	return nil, ErrReadTimeout
 }
 
 func (dev *SPIFriend) sendInitializePattern() error {
 	if dev.verbose {
 		dev.debug("entered sendInitializePattern()\r")
 	}
-	dev.cs.Low()
-	defer dev.cs.High()
-
-	return dev.sendPacket(sdep.CmdTypeInitialize, nil, false)
+	dev.msg.Header = sdep.Header{Type: sdep.MsgTypeCommand, ID: sdep.CmdTypeInitialize}
+	return dev.sendPacket()
 }
 
-func (dev *SPIFriend) sendPacket(cmd uint16, buf []byte, moreData bool) error {
+// sendPacket writes the packet in dev.msg, retrying while the module is not
+// ready
+func (dev *SPIFriend) sendPacket() error {
+
+	n, err := dev.msg.MarshalTo(dev.pkt[:])
+	if err != nil {
+		return err
+	}
 
-	dev.cs.Low()
-	defer dev.cs.High()
+	dev.bus.Select(true)
+	defer dev.bus.Select(false)
 
 	if dev.verbose {
-		dev.debug("Sending command packet: %04X", cmd)
+		dev.debug("sending %s", dev.msg.String())
 	}
 
 	// flush old response before sending the new command, but only if we're *not*
@@ -193,135 +262,97 @@ func (dev *SPIFriend) sendPacket(cmd uint16, buf []byte, moreData bool) error {
 	// been read yet
 	//if (more_data == 0 && _mode != BLUEFRUIT_MODE_DATA) flush();
 
-	length := 0
-	if buf != nil {
-		length = len(buf)
-		if length > sdep.MaxPacketSize {
-			return ErrPacketTooLarge
-		}
-	}
-
-	dev.msg.Header.Type = sdep.MsgTypeCommand
-	dev.msg.Header.ID = cmd
-	dev.msg.Header.Size = 0
-
-	if buf != nil {
-		n := uint8(len(buf))
-		if n > sdep.MaxPayloadSize {
-			return ErrPacketTooLarge
-		}
-		dev.msg.Header.Size = n
-		if n == sdep.MaxPayloadSize {
-			if moreData {
-				dev.msg.Header.Size |= uint8(1 << 7)
-			}
-		}
-		copy(dev.msg.Payload[:n], buf)
-	}
-
-	if dev.verbose {
-		dev.debug("sending %s", dev.msg.String())
-	}
-
 	var b byte
 	// Loop until Bluefruit is ready
 	for i := 0; i < 25; i++ {
-		b, _ := dev.bus.Transfer(byte(dev.msg.Header.Type))
+		b, _ = dev.bus.Transfer(dev.pkt[0])
 		if b != uint8(ErrSlaveDeviceNotReady) {
 			break
 		}
 		if dev.verbose {
 			dev.debug("bluefruit not ready")
 		}
-		dev.cs.High()
-		mandatoryDelay()
-		dev.cs.Low()
+		dev.bus.Select(false)
+		dev.mandatoryDelay()
+		dev.bus.Select(true)
 	}
 	if b == uint8(ErrSlaveDeviceNotReady) {
-		return fmt.Errorf("write timeout")
+		return ErrWriteTimeout
 	}
 
 	// send the rest of the data
-	dev.bus.Transfer(byte(uint8(dev.msg.Header.ID & 0xFF)))
-	dev.bus.Transfer(byte(uint8(dev.msg.Header.ID >> 0x8)))
-	if length > 0 {
-		dev.bus.Transfer(byte(dev.msg.Header.Size))
-		dev.bus.Tx(buf, nil)
-	} else {
-		dev.bus.Transfer(0xFF)
-	}
+	err = dev.bus.Tx(dev.pkt[1:n], nil)
 
 	if dev.verbose {
 		dev.debug("Finished sending command packet")
 	}
 
-	return nil
+	return err
+}
+
+// resync deselects and reselects the module after it has answered that it is
+// not ready, so that the next read starts a new transaction
+func (dev *SPIFriend) resync() {
+	dev.bus.Select(false)
+	dev.delay()
+	dev.bus.Select(true)
+	dev.mandatoryDelay()
 }
 
+// readPacket reads a packet of any type into dev.msg
 func (dev *SPIFriend) readPacket() (err error) {
 	if dev.verbose {
 		dev.debug("Attempting to read packet")
 	}
 
-	dev.msg.Header.Type, _ = dev.bus.Transfer(0xFF)
-	dev.msg.Header.ID = 0
-	dev.msg.Header.Size = 0
+	typ, _ := dev.bus.Transfer(0xFF)
 
 	if dev.verbose {
-		dev.debug("read type byte: %02X", dev.msg.Header.Type)
+		dev.debug("read type byte: %02X", typ)
 	}
 
-	switch dev.msg.Header.Type {
-	case sdep.MsgTypeCommand:
-		err = fmt.Errorf("Unexpected message type: command")
-	case sdep.MsgTypeResponse:
-		err = nil
-	case sdep.MsgTypeAlert:
-		err = fmt.Errorf("Unexpected message type: alert")
-	case sdep.MsgTypeError:
-		err = fmt.Errorf("Unexpected message type: error")
+	switch typ {
 	case sdep.ErrSlaveDeviceNotReady:
 		return ErrSlaveDeviceNotReady
 	case sdep.ErrSlaveDeviceReadOverflow:
 		return ErrSlaveDeviceReadOverflow
-	default:
-		return fmt.Errorf("Unexpected byte from slave device: %02X", dev.msg.Header.Type)
 	}
-
-	buf := make([]byte, 3)
-	dev.bus.Tx(nil, buf)
-	dev.msg.Header.ID = uint16(buf[0])
-	dev.msg.Header.ID |= uint16(buf[1]) << 8
-	dev.msg.Header.Size = uint8(buf[2])
-	if dev.msg.Header.Type != sdep.MsgTypeError {
-		length := dev.msg.Header.GetLength()
-		if length > sdep.MaxPayloadSize {
-			length = sdep.MaxPayloadSize // TODO: this should probably be handled better
-		}
-		if length > 0 {
-			dev.bus.Tx(nil, dev.msg.Payload[:length])
+	if !sdep.IsValidType(typ) {
+		if dev.verbose {
+			dev.debug("unexpected byte: %02X", typ)
 		}
+		return ErrUnexpectedByte
 	}
 
-	return
+	dev.pkt[0] = typ
+	dev.bus.Tx(nil, dev.pkt[1:sdep.HeaderSize])
+	length := int(dev.pkt[3] & 31)
+	if length > sdep.MaxPayloadSize {
+		return sdep.ErrPayloadTooLarge
+	}
+	if length > 0 {
+		dev.bus.Tx(nil, dev.pkt[sdep.HeaderSize:sdep.HeaderSize+length])
+	}
+	_, err = dev.msg.Unmarshal(dev.pkt[:sdep.HeaderSize+length])
+	return err
 }
 
 func (dev *SPIFriend) debug(format string, args ...interface{}) {
-	fmt.Printf("[SPIFRIEND %d] ", time.Now().UnixNano())
+	fmt.Printf("[SPIFRIEND %d] ", dev.clock.Now())
 	fmt.Printf(format, args...)
 	println("\r")
 }
 
-func mandatoryDelay() {
-	delayMicros(100)
+func (dev *SPIFriend) mandatoryDelay() {
+	dev.delayMicros(100)
 }
 
-func delay() {
-	delayMicros(10)
+func (dev *SPIFriend) delay() {
+	dev.delayMicros(10)
 }
 
-func delayMicros(usecs uint32) {
-	timer.Wait(time.Duration(usecs) * time.Microsecond)
+func (dev *SPIFriend) delayMicros(usecs uint32) {
+	dev.clock.Sleep(time.Duration(usecs) * time.Microsecond)
 }
 
 /*
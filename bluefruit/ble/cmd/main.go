@@ -43,15 +43,15 @@ var (
 	state   = StateInput
 
 	commands map[string]cmdfunc = map[string]cmdfunc{
-		"":     cmdfunc(noop),
-		"dbg":  cmdfunc(dbg),
-		"cs":   cmdfunc(cs),
-		"irq":  cmdfunc(irq),
-		"send": cmdfunc(send),
+		"":      cmdfunc(noop),
+		"dbg":   cmdfunc(dbg),
+		"cs":    cmdfunc(cs),
+		"irq":   cmdfunc(irq),
+		"send":  cmdfunc(send),
+		"echo":  cmdfunc(echo),
+		"info":  cmdfunc(info),
+		"reset": cmdfunc(reset),
 		//		"read":  cmdfunc(read),
-		//		"echo":  cmdfunc(echo),
-		//		"info":  cmdfunc(info),
-		//		"reset": cmdfunc(reset),
 		//		"check": cmdfunc(check),
 	}
 
@@ -110,21 +110,21 @@ func read(argv []string) {
 	}
 	println(msg.String(), "\r")
 }
+*/
 
 func echo(argv []string) {
 	if len(argv) != 2 {
 		println("Usage: echo <on|off>\r")
+		return
 	}
 	newState := argv[1]
-	if newState == "on" {
-		spifriend.Echo(true)
+	if newState != "on" && newState != "off" {
+		println("Usage: echo <on|off>\r")
 		return
 	}
-	if newState == "off" {
-		spifriend.Echo(false)
-		return
+	if err := spifriend.Echo(newState == "on"); err != nil {
+		println("Error setting echo: ", err.Error(), "\r")
 	}
-	println("Usage: echo <on|off>\r")
 }
 
 func info(argv []string) {
@@ -132,7 +132,8 @@ func info(argv []string) {
 	var err error
 	println("Requesting Bluefruit info...\r")
 	if i, err = spifriend.Info(); err != nil {
-		println("Error getting Bluefruit info: ", err, "\r")
+		println("Error getting Bluefruit info: ", err.Error(), "\r")
+		return
 	}
 	fmt.Printf("%s\r\n", i.String())
 }
@@ -145,7 +146,6 @@ func reset(argv []string) {
 		println("issued reset successfully\r")
 	}
 }
-*/
 
 type cmdfunc func(argv []string)
 
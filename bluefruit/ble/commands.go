@@ -0,0 +1,294 @@
+package ble
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+)
+
+// ATError is returned by Command when the module does not answer OK; Status
+// is either ERROR or, if the response has no status at all, its last line.
+type ATError struct {
+	Command string
+	Status  string
+}
+
+func (err *ATError) Error() string {
+	return "ble: " + err.Command + ": " + err.Status
+}
+
+// Command sends an AT command and returns the response without the trailing
+// OK line, or an *ATError if the module answered ERROR
+func (dev *SPIFriend) Command(command string) ([]byte, error) {
+	rsp, err := dev.SendAT(command)
+	return checkStatus(command, rsp, err)
+}
+
+// checkStatus strips the status line from the response to a command.  A
+// response that was cut short is returned as is, since it has no status.
+func checkStatus(command string, rsp []byte, err error) ([]byte, error) {
+	if err == ErrResponseTooLarge {
+		return rsp, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	body, status := splitStatus(rsp)
+	if string(status) != "OK" {
+		return body, &ATError{Command: command, Status: string(status)}
+	}
+	return body, nil
+}
+
+const keyboardCode = "AT+BLEKEYBOARDCODE="
+
+// KeyboardCode sends a boot protocol keyboard report with
+// AT+BLEKEYBOARDCODE.  The command is formatted in place in the command
+// buffer, so sending a report does not allocate unless there is an error.
+func (dev *SPIFriend) KeyboardCode(report *[8]byte) error {
+	cmd := append(dev.cmd[:0], keyboardCode...)
+	for i, b := range report {
+		if i > 0 {
+			cmd = append(cmd, '-')
+		}
+		cmd = append(cmd, hexDigits[b>>4], hexDigits[b&0xF])
+	}
+	return dev.sendCommand(cmd)
+}
+
+// ControlKey sends a press and release of a consumer control key with
+// AT+BLEHIDCONTROLKEY, using its raw HID usage ID; like KeyboardCode it does
+// not allocate unless there is an error.
+func (dev *SPIFriend) ControlKey(usage uint16) error {
+	cmd := append(dev.cmd[:0], "AT+BLEHIDCONTROLKEY=0x"...)
+	cmd = append(cmd,
+		hexDigits[usage>>12], hexDigits[usage>>8&0xF],
+		hexDigits[usage>>4&0xF], hexDigits[usage&0xF])
+	return dev.sendCommand(cmd)
+}
+
+const mouseButtons = "LRMBF"
+
+// MouseButton sets the state of the mouse buttons with AT+BLEHIDMOUSEBUTTON;
+// bits 0 to 4 of buttons are the left, right, middle, back and forward
+// buttons.  It does not allocate unless there is an error.
+func (dev *SPIFriend) MouseButton(buttons uint8) error {
+	cmd := append(dev.cmd[:0], "AT+BLEHIDMOUSEBUTTON="...)
+	if buttons&0x1F == 0 {
+		cmd = append(cmd, '0')
+	}
+	for i := 0; i < len(mouseButtons); i++ {
+		if buttons&(1<<uint(i)) > 0 {
+			cmd = append(cmd, mouseButtons[i])
+		}
+	}
+	return dev.sendCommand(cmd)
+}
+
+// MouseMove moves the cursor and scrolls the wheels with AT+BLEHIDMOUSEMOVE.
+// It does not allocate unless there is an error.
+func (dev *SPIFriend) MouseMove(x, y, wheel, pan int8) error {
+	cmd := append(dev.cmd[:0], "AT+BLEHIDMOUSEMOVE="...)
+	cmd = strconv.AppendInt(cmd, int64(x), 10)
+	cmd = append(cmd, ',')
+	cmd = strconv.AppendInt(cmd, int64(y), 10)
+	cmd = append(cmd, ',')
+	cmd = strconv.AppendInt(cmd, int64(wheel), 10)
+	cmd = append(cmd, ',')
+	cmd = strconv.AppendInt(cmd, int64(pan), 10)
+	return dev.sendCommand(cmd)
+}
+
+// sendCommand sends a command that has been formatted in the command buffer
+// and checks that the module answered OK
+func (dev *SPIFriend) sendCommand(cmd []byte) error {
+	rsp, err := dev.transact(sdep.CmdTypeATWrapper, cmd)
+	if err != nil {
+		return err
+	}
+	if _, status := splitStatus(rsp); string(status) != "OK" {
+		return &ATError{Command: string(cmd), Status: string(status)}
+	}
+	return nil
+}
+
+// splitStatus separates the last line of a response from the lines before it
+func splitStatus(rsp []byte) (body []byte, status []byte) {
+	rsp = bytes.TrimRight(rsp, "\r\n")
+	i := bytes.LastIndexByte(rsp, '\n')
+	return bytes.TrimRight(rsp[:i+1], "\r\n"), rsp[i+1:]
+}
+
+// Info is the device information returned by ATI
+type Info struct {
+	Board      string
+	MCU        string
+	Serial     string
+	Codebase   string
+	Firmware   string
+	BuildDate  string
+	SoftDevice string
+}
+
+func (info *Info) String() string {
+	return strings.Join([]string{
+		"Board:       " + info.Board,
+		"MCU:         " + info.MCU,
+		"Serial:      " + info.Serial,
+		"Codebase:    " + info.Codebase,
+		"Firmware:    " + info.Firmware,
+		"Build date:  " + info.BuildDate,
+		"SoftDevice:  " + info.SoftDevice,
+	}, "\r\n")
+}
+
+func (dev *SPIFriend) Info() (info Info, err error) {
+	rsp, err := dev.Command("ATI")
+	if err != nil {
+		return info, err
+	}
+	fields := []*string{
+		&info.Board, &info.MCU, &info.Serial, &info.Codebase,
+		&info.Firmware, &info.BuildDate, &info.SoftDevice,
+	}
+	for i, line := range strings.Split(string(rsp), "\n") {
+		if i < len(fields) {
+			*fields[i] = strings.TrimSpace(line)
+		}
+	}
+	return info, nil
+}
+
+// ATZ performs a software reset and waits for the module to restart; it is
+// needed for some settings such as AT+BLEKEYBOARDEN to take effect.
+func (dev *SPIFriend) ATZ() error {
+	if _, err := dev.Command("ATZ"); err != nil {
+		return err
+	}
+	dev.clock.Sleep(1 * time.Second)
+	return nil
+}
+
+func (dev *SPIFriend) Echo(enabled bool) error {
+	_, err := dev.Command("ATE=" + flag(enabled))
+	return err
+}
+
+func (dev *SPIFriend) DeviceName() (string, error) {
+	rsp, err := dev.Command("AT+GAPDEVNAME")
+	return string(rsp), err
+}
+
+func (dev *SPIFriend) SetDeviceName(name string) error {
+	_, err := dev.Command("AT+GAPDEVNAME=" + name)
+	return err
+}
+
+// SetKeyboardEnabled enables or disables the HID keyboard service, which
+// takes effect after a reset
+func (dev *SPIFriend) SetKeyboardEnabled(enabled bool) error {
+	_, err := dev.Command("AT+BLEKEYBOARDEN=" + flag(enabled))
+	return err
+}
+
+// SetBatteryEnabled enables or disables the Battery Service, which takes
+// effect after a reset
+func (dev *SPIFriend) SetBatteryEnabled(enabled bool) error {
+	_, err := dev.Command("AT+BLEBATTEN=" + flag(enabled))
+	return err
+}
+
+// SetBatteryLevel sets the level in percent reported by the Battery Service
+func (dev *SPIFriend) SetBatteryLevel(percent uint8) error {
+	if percent > 100 {
+		percent = 100
+	}
+	_, err := dev.Command("AT+BLEBATTVAL=" + strconv.Itoa(int(percent)))
+	return err
+}
+
+// Connected returns true if a central is connected to the module
+func (dev *SPIFriend) Connected() (bool, error) {
+	rsp, err := dev.Command("AT+GAPGETCONN")
+	if err != nil {
+		return false, err
+	}
+	return string(rsp) == "1", nil
+}
+
+func (dev *SPIFriend) Disconnect() error {
+	_, err := dev.Command("AT+GAPDISCONNECT")
+	return err
+}
+
+func (dev *SPIFriend) StartAdvertising() error {
+	_, err := dev.Command("AT+GAPSTARTADV")
+	return err
+}
+
+func (dev *SPIFriend) StopAdvertising() error {
+	_, err := dev.Command("AT+GAPSTOPADV")
+	return err
+}
+
+// Address is a Bluetooth device address, most significant byte first
+type Address [6]byte
+
+// ParseAddress parses an address in the form returned by AT+BLEGETADDR,
+// e.g. E4:C6:C7:31:95:11
+func ParseAddress(s string) (addr Address, err error) {
+	if len(s) != 17 {
+		return addr, strconv.ErrSyntax
+	}
+	for i := range addr {
+		if i > 0 && s[i*3-1] != ':' {
+			return addr, strconv.ErrSyntax
+		}
+		b, err := strconv.ParseUint(s[i*3:i*3+2], 16, 8)
+		if err != nil {
+			return addr, err
+		}
+		addr[i] = byte(b)
+	}
+	return addr, nil
+}
+
+func (addr Address) String() string {
+	var b [17]byte
+	for i, c := range addr {
+		if i > 0 {
+			b[i*3-1] = ':'
+		}
+		b[i*3] = hexDigits[c>>4]
+		b[i*3+1] = hexDigits[c&0xF]
+	}
+	return string(b[:])
+}
+
+func (dev *SPIFriend) Address() (Address, error) {
+	rsp, err := dev.Command("AT+BLEGETADDR")
+	if err != nil {
+		return Address{}, err
+	}
+	return ParseAddress(string(rsp))
+}
+
+// RSSI returns the signal strength of the current connection in dBm, or 0 if
+// there is no connection
+func (dev *SPIFriend) RSSI() (int, error) {
+	rsp, err := dev.Command("AT+BLEGETRSSI")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(rsp))
+}
+
+func flag(enabled bool) string {
+	if enabled {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,177 @@
+package ble_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+// cannedBus is a transport that answers every command with the same
+// preallocated response, so that it does not allocate itself
+type cannedBus struct {
+	resp    []byte
+	pos     int
+	pending bool
+}
+
+func (bus *cannedBus) Configure() error { return nil }
+func (bus *cannedBus) Select(bool)      {}
+func (bus *cannedBus) IRQ() bool        { return bus.pending }
+func (bus *cannedBus) Reset(bool) bool  { return false }
+
+func (bus *cannedBus) Transfer(w byte) (byte, error) {
+	if !bus.pending {
+		return 0, nil
+	}
+	b := bus.resp[bus.pos]
+	bus.pos++
+	return b, nil
+}
+
+func (bus *cannedBus) Tx(w, r []byte) error {
+	if r != nil {
+		bus.pos += copy(r, bus.resp[bus.pos:])
+		bus.pending = bus.pos < len(bus.resp)
+		return nil
+	}
+	// the response is ready once the last packet of a command is written
+	if len(w) >= 3 && w[2]&0x80 == 0 {
+		bus.pending, bus.pos = true, 0
+	}
+	return nil
+}
+
+func TestCommandsDoNotAllocate(t *testing.T) {
+	bus := &cannedBus{resp: []byte{sdep.MsgTypeResponse, 0x00, 0x0A, 4, 'O', 'K', '\r', '\n'}}
+	dev := ble.New(bus)
+	if err := dev.Begin(ble.SPIFriendConfig{Clock: timer.NewFake()}); err != nil {
+		t.Fatal(err)
+	}
+	var report [8]byte
+	tests := []struct {
+		name string
+		send func() error
+	}{
+		{"KeyboardCode", func() error { report[2]++; return dev.KeyboardCode(&report) }},
+		{"ControlKey", func() error { return dev.ControlKey(0x00E9) }},
+		{"MouseButton", func() error { return dev.MouseButton(0x05) }},
+		{"MouseMove", func() error { return dev.MouseMove(-10, 127, -128, 1) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				if err := tt.send(); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs != 0 {
+				t.Errorf("%v allocations per report", allocs)
+			}
+		})
+	}
+}
+
+func TestHIDCommands(t *testing.T) {
+	mod, dev, _ := begin(t)
+	tests := []struct {
+		send func() error
+		want string
+	}{
+		{func() error {
+			return dev.KeyboardCode(&[8]byte{0x02, 0x00, 0x04, 0xAB, 0, 0, 0, 0xFF})
+		}, "AT+BLEKEYBOARDCODE=02-00-04-AB-00-00-00-FF"},
+		{func() error { return dev.ControlKey(0x00E9) }, "AT+BLEHIDCONTROLKEY=0x00E9"},
+		{func() error { return dev.ControlKey(0x0A2B) }, "AT+BLEHIDCONTROLKEY=0x0A2B"},
+		{func() error { return dev.MouseButton(0) }, "AT+BLEHIDMOUSEBUTTON=0"},
+		{func() error { return dev.MouseButton(0x05) }, "AT+BLEHIDMOUSEBUTTON=LM"},
+		{func() error { return dev.MouseButton(0x1F) }, "AT+BLEHIDMOUSEBUTTON=LRMBF"},
+		{func() error { return dev.MouseMove(0, 0, 0, 0) }, "AT+BLEHIDMOUSEMOVE=0,0,0,0"},
+		{func() error { return dev.MouseMove(-10, 127, -128, 1) }, "AT+BLEHIDMOUSEMOVE=-10,127,-128,1"},
+	}
+	for _, tt := range tests {
+		mod.RespondOK(tt.want)
+		n := len(mod.Commands())
+		if err := tt.send(); err != nil {
+			t.Errorf("%s: %v", tt.want, err)
+		}
+		if cmds := mod.Commands()[n:]; len(cmds) != 1 || cmds[0] != tt.want {
+			t.Errorf("sent %q, want %q", cmds, tt.want)
+		}
+	}
+
+	// an error reports the command that failed
+	mod.Respond("AT+BLEHIDCONTROLKEY=0x00CD", "ERROR\r\n")
+	err := dev.ControlKey(0x00CD)
+	if atErr, ok := err.(*ble.ATError); !ok || atErr.Command != "AT+BLEHIDCONTROLKEY=0x00CD" || atErr.Status != "ERROR" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+// errAT stands for any *ATError in the expected results
+var errAT = errors.New("AT error")
+
+func TestCheckStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		rsp  string
+		err  error
+		body string
+		// status is the status of the *ATError expected if want is errAT
+		status string
+		want   error
+	}{
+		{"ok", "BLESPIFRIEND\r\nOK\r\n", nil, "BLESPIFRIEND", "", nil},
+		{"ok without body", "OK\r\n", nil, "", "", nil},
+		{"several lines", "a\r\nb\r\nOK\r\n", nil, "a\r\nb", "", nil},
+		{"error", "partial\r\nERROR\r\n", nil, "partial", "ERROR", errAT},
+		{"missing status", "1\r\n", nil, "", "1", errAT},
+		{"empty", "", nil, "", "", errAT},
+		{"too large", "cut sho", ble.ErrResponseTooLarge, "cut sho", "", ble.ErrResponseTooLarge},
+		{"transport error", "", ble.ErrReadTimeout, "", "", ble.ErrReadTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := ble.CheckStatus("AT+TEST", []byte(tt.rsp), tt.err)
+			if string(body) != tt.body {
+				t.Errorf("got body %q, want %q", body, tt.body)
+			}
+			if tt.want != errAT {
+				if err != tt.want {
+					t.Errorf("got error %v, want %v", err, tt.want)
+				}
+				return
+			}
+			var atErr *ble.ATError
+			if !errors.As(err, &atErr) || atErr.Command != "AT+TEST" || atErr.Status != tt.status {
+				t.Errorf("got error %v, want an *ATError with status %q", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	addr, err := ble.ParseAddress("e4:c6:C7:31:95:11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := addr.String(); s != "E4:C6:C7:31:95:11" {
+		t.Fatalf("got %s", s)
+	}
+	for _, s := range []string{
+		"",
+		"E4:C6:C7:31:95",
+		"E4:C6:C7:31:95:11:",
+		"E4-C6-C7-31-95-11",
+		"E4:C6:C7:31:95:1G",
+		"E4:C6:C7:31:95:+1",
+		" E4:C6:C7:31:95:1",
+	} {
+		if _, err := ble.ParseAddress(s); !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("ParseAddress(%q): got error %v, want %v", s, err, strconv.ErrSyntax)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package ble
+
+import (
+	"time"
+
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+const (
+	DefaultPollInterval       = 1 * time.Second
+	DefaultAdvertisingTimeout = 30 * time.Second
+)
+
+// Connection tracks whether a central is connected by polling AT+GAPGETCONN,
+// and restarts advertising when no central has connected for a while.
+//
+// Polling is used rather than waiting for connect and disconnect alerts,
+// since it works the same on every firmware version and needs no setup on the
+// module.  The cost is a blocking AT round-trip from Task every poll interval,
+// which delays the scan of the matrix by as long as the module takes to
+// answer.
+type Connection struct {
+	dev   *SPIFriend
+	clock timer.Clock
+
+	pollInterval time.Duration
+	advTimeout   time.Duration
+	poll         timer.Timer
+	adv          timer.Timer
+	polled       bool
+
+	connected    bool
+	onConnect    func()
+	onDisconnect func()
+}
+
+// NewConnection returns a tracker for dev, which should already have been
+// started with Begin so that it uses the configured clock
+func NewConnection(dev *SPIFriend) *Connection {
+	return &Connection{
+		dev:          dev,
+		clock:        dev.clock,
+		pollInterval: DefaultPollInterval,
+		advTimeout:   DefaultAdvertisingTimeout,
+		adv:          timer.New(dev.clock, DefaultAdvertisingTimeout),
+	}
+}
+
+// WithPollInterval sets how often AT+GAPGETCONN is sent
+func (conn *Connection) WithPollInterval(interval time.Duration) *Connection {
+	conn.pollInterval = interval
+	return conn
+}
+
+// WithAdvertisingTimeout sets how long to wait for a central before
+// advertising is restarted; zero disables re-advertising
+func (conn *Connection) WithAdvertisingTimeout(timeout time.Duration) *Connection {
+	conn.advTimeout = timeout
+	conn.adv = timer.New(conn.clock, timeout)
+	return conn
+}
+
+// WithCallbacks sets functions to call when a central connects or disconnects;
+// either may be nil
+func (conn *Connection) WithCallbacks(onConnect, onDisconnect func()) *Connection {
+	conn.onConnect = onConnect
+	conn.onDisconnect = onDisconnect
+	return conn
+}
+
+// Connected returns the connection state as of the last poll
+func (conn *Connection) Connected() bool {
+	return conn.connected
+}
+
+// Task polls the module when the poll interval has elapsed and returns an
+// error if the module could not be queried
+func (conn *Connection) Task() error {
+	if conn.polled && !conn.poll.Expired() {
+		return nil
+	}
+	conn.polled = true
+	conn.poll = timer.New(conn.clock, conn.pollInterval)
+	connected, err := conn.dev.Connected()
+	if err != nil {
+		return err
+	}
+	conn.SetConnected(connected)
+	if !connected && conn.advTimeout > 0 && conn.adv.Expired() {
+		conn.adv = timer.New(conn.clock, conn.advTimeout)
+		return conn.dev.StartAdvertising()
+	}
+	return nil
+}
+
+// SetConnected updates the connection state, calling the connect or
+// disconnect callback if it has changed.  Task calls it after each poll; it
+// can also be called when the state is known some other way.
+func (conn *Connection) SetConnected(connected bool) {
+	if connected == conn.connected {
+		return
+	}
+	conn.connected = connected
+	conn.adv = timer.New(conn.clock, conn.advTimeout)
+	if connected && conn.onConnect != nil {
+		conn.onConnect()
+	} else if !connected && conn.onDisconnect != nil {
+		conn.onDisconnect()
+	}
+}
@@ -0,0 +1,146 @@
+package ble_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+	"github.com/bgould/tinygo-model-m/bluefruit/ble/sim"
+)
+
+// count returns the number of times the module received command
+func count(mod *sim.Module, command string) int {
+	n := 0
+	for _, c := range mod.Commands() {
+		if c == command {
+			n++
+		}
+	}
+	return n
+}
+
+func TestConnectionPollInterval(t *testing.T) {
+	mod, dev, clock := begin(t)
+	mod.RespondOK("AT+GAPGETCONN", "0")
+	conn := ble.NewConnection(dev).WithPollInterval(500 * time.Millisecond)
+
+	// every call to Task shifts the clock a little, since the SPI delays
+	// sleep on it; steps of half the interval keep clear of the edges
+	want := 0
+	for i := 0; i < 10; i++ {
+		if err := conn.Task(); err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			want++
+		}
+		if got := count(mod, "AT+GAPGETCONN"); got != want {
+			t.Fatalf("step %d: polled %d times, want %d", i, got, want)
+		}
+		clock.Advance(250 * time.Millisecond)
+	}
+}
+
+func TestConnectionCallbacks(t *testing.T) {
+	mod, dev, clock := begin(t)
+	var events []string
+	conn := ble.NewConnection(dev).WithCallbacks(
+		func() { events = append(events, "connect") },
+		func() { events = append(events, "disconnect") },
+	)
+	states := []string{"0", "1", "1", "0", "0", "1"}
+	for i, state := range states {
+		mod.RespondOK("AT+GAPGETCONN", state)
+		if err := conn.Task(); err != nil {
+			t.Fatal(err)
+		}
+		if conn.Connected() != (state == "1") {
+			t.Errorf("poll %d: got connected %t", i, conn.Connected())
+		}
+		clock.Advance(ble.DefaultPollInterval)
+	}
+	want := []string{"connect", "disconnect", "connect"}
+	if len(events) != len(want) {
+		t.Fatalf("got callbacks %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("got callbacks %q, want %q", events, want)
+			break
+		}
+	}
+
+	// a state set some other way is not reported again by the next poll
+	conn.SetConnected(false)
+	mod.RespondOK("AT+GAPGETCONN", "0")
+	conn.Task()
+	if len(events) != 4 || events[3] != "disconnect" {
+		t.Errorf("got callbacks %q", events)
+	}
+}
+
+func TestConnectionAdvertisingTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		states  func(sec int) string
+		want    int
+	}{
+		{
+			name:    "restarted every timeout while disconnected",
+			timeout: 10 * time.Second,
+			states:  func(int) string { return "0" },
+			want:    2,
+		},
+		{
+			name:    "not restarted while connected",
+			timeout: 10 * time.Second,
+			states:  func(int) string { return "1" },
+			want:    0,
+		},
+		{
+			name:    "timeout starts over after a disconnect",
+			timeout: 10 * time.Second,
+			states: func(sec int) string {
+				if sec >= 5 && sec < 15 {
+					return "1"
+				}
+				return "0"
+			},
+			want: 0,
+		},
+		{
+			name:    "disabled",
+			timeout: 0,
+			states:  func(int) string { return "0" },
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mod, dev, clock := begin(t)
+			mod.RespondOK("AT+GAPSTARTADV")
+			conn := ble.NewConnection(dev).WithAdvertisingTimeout(tt.timeout)
+			for sec := 0; sec < 24; sec++ {
+				mod.RespondOK("AT+GAPGETCONN", tt.states(sec))
+				if err := conn.Task(); err != nil {
+					t.Fatal(err)
+				}
+				clock.Advance(time.Second)
+			}
+			if got := count(mod, "AT+GAPSTARTADV"); got != tt.want {
+				t.Errorf("advertising restarted %d times, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+	_, dev, _ := begin(t)
+	conn := ble.NewConnection(dev)
+	var atErr *ble.ATError
+	if err := conn.Task(); !errors.As(err, &atErr) || atErr.Command != "AT+GAPGETCONN" {
+		t.Fatalf("got error %v, want an *ATError for AT+GAPGETCONN", err)
+	}
+}
@@ -0,0 +1,7 @@
+package ble
+
+var (
+	CheckStatus   = checkStatus
+	HexBytes      = hexBytes
+	ParseHexBytes = parseHexBytes
+)
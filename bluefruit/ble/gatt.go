@@ -0,0 +1,197 @@
+package ble
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidDescription is returned for a description with a comma in it,
+	// which the module would take for the start of the next parameter
+	ErrInvalidDescription = errors.New("invalid description")
+
+	// ErrNoIndex is returned when the module answers a GATT command without
+	// the index of what it added
+	ErrNoIndex = errors.New("no index in response")
+)
+
+// UUID identifies a service or characteristic, in the form that the GATT AT
+// commands expect
+type UUID string
+
+// UUID16 returns a 16-bit UUID assigned by the Bluetooth SIG
+func UUID16(uuid uint16) UUID {
+	return UUID("UUID=0x" + hexByte(byte(uuid>>8)) + hexByte(byte(uuid)))
+}
+
+// UUID128 returns a custom 128-bit UUID, written as 16 hex bytes separated by
+// dashes, e.g. 00-11-22-33-44-55-66-77-88-99-AA-BB-CC-DD-EE-FF
+func UUID128(uuid string) UUID {
+	return UUID("UUID128=" + uuid)
+}
+
+// Property is a bitmask of the operations supported by a characteristic
+type Property uint8
+
+const (
+	PropRead                 Property = 0x02
+	PropWriteWithoutResponse Property = 0x04
+	PropWrite                Property = 0x08
+	PropNotify               Property = 0x10
+	PropIndicate             Property = 0x20
+)
+
+// Service is a custom GATT service added to the module
+type Service struct {
+	dev             *SPIFriend
+	Index           uint8
+	UUID            UUID
+	Characteristics []*Characteristic
+}
+
+// Characteristic is a characteristic of a custom service.  Values are byte
+// arrays of between MinLen and MaxLen bytes.
+type Characteristic struct {
+	dev         *SPIFriend
+	Index       uint8
+	UUID        UUID
+	Properties  Property
+	MinLen      uint8
+	MaxLen      uint8
+	Description string
+}
+
+// ClearGATT removes all custom services from the module, which takes effect
+// after a reset
+func (dev *SPIFriend) ClearGATT() error {
+	dev.services = nil
+	_, err := dev.Command("AT+GATTCLEAR")
+	return err
+}
+
+// Services returns the custom services added since the last ClearGATT
+func (dev *SPIFriend) Services() []*Service {
+	return dev.services
+}
+
+// AddService adds a custom service, which is only advertised after the module
+// has been reset with ATZ.  Characteristics added to the service afterwards
+// belong to it until the next service is added.
+func (dev *SPIFriend) AddService(uuid UUID) (*Service, error) {
+	index, err := dev.commandIndex("AT+GATTADDSERVICE=" + string(uuid))
+	if err != nil {
+		return nil, err
+	}
+	svc := &Service{dev: dev, Index: index, UUID: uuid}
+	dev.services = append(dev.services, svc)
+	return svc, nil
+}
+
+// AddCharacteristic adds a characteristic with the UUID, properties, length
+// range and description set in c, and the initial value.  The description
+// cannot contain commas.
+func (svc *Service) AddCharacteristic(c Characteristic, value []byte) (*Characteristic, error) {
+	if strings.IndexByte(c.Description, ',') >= 0 {
+		return nil, ErrInvalidDescription
+	}
+	var b strings.Builder
+	b.WriteString("AT+GATTADDCHAR=")
+	b.WriteString(string(c.UUID))
+	b.WriteString(",PROPERTIES=0x")
+	b.WriteString(hexByte(byte(c.Properties)))
+	b.WriteString(",MIN_LEN=")
+	b.WriteString(strconv.Itoa(int(c.MinLen)))
+	b.WriteString(",MAX_LEN=")
+	b.WriteString(strconv.Itoa(int(c.MaxLen)))
+	// DATATYPE=2 is a byte array, so that the value is not taken for a number
+	b.WriteString(",DATATYPE=2")
+	if len(value) > 0 {
+		b.WriteString(",VALUE=")
+		b.WriteString(hexBytes(value))
+	}
+	if c.Description != "" {
+		b.WriteString(",DESCRIPTION=")
+		b.WriteString(c.Description)
+	}
+	index, err := svc.dev.commandIndex(b.String())
+	if err != nil {
+		return nil, err
+	}
+	c.dev = svc.dev
+	c.Index = index
+	svc.Characteristics = append(svc.Characteristics, &c)
+	return &c, nil
+}
+
+// Read copies the current value into buf and returns its length
+func (c *Characteristic) Read(buf []byte) (int, error) {
+	rsp, err := c.dev.Command("AT+GATTCHAR=" + strconv.Itoa(int(c.Index)))
+	if err != nil {
+		return 0, err
+	}
+	return parseHexBytes(string(rsp), buf)
+}
+
+// Write sets the value, notifying or indicating a connected central if the
+// characteristic has those properties
+func (c *Characteristic) Write(value []byte) error {
+	_, err := c.dev.Command("AT+GATTCHAR=" + strconv.Itoa(int(c.Index)) + "," + hexBytes(value))
+	return err
+}
+
+// commandIndex sends a command that answers with the index of what it added
+func (dev *SPIFriend) commandIndex(command string) (uint8, error) {
+	rsp, err := dev.Command(command)
+	if err != nil {
+		return 0, err
+	}
+	if len(rsp) == 0 {
+		return 0, ErrNoIndex
+	}
+	index, err := strconv.ParseUint(string(rsp), 10, 8)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(index), nil
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+func hexByte(b byte) string {
+	return string([]byte{hexDigits[b>>4], hexDigits[b&0xF]})
+}
+
+// hexBytes formats a value as hex bytes separated by dashes, e.g. 01-02-03
+func hexBytes(value []byte) string {
+	var b strings.Builder
+	for i, v := range value {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(hexDigits[v>>4])
+		b.WriteByte(hexDigits[v&0xF])
+	}
+	return b.String()
+}
+
+// parseHexBytes parses hex bytes separated by dashes, each of which may have
+// a 0x prefix, into buf
+func parseHexBytes(s string, buf []byte) (n int, err error) {
+	if s == "" {
+		return 0, nil
+	}
+	for _, field := range strings.Split(s, "-") {
+		field = strings.TrimPrefix(strings.TrimPrefix(field, "0x"), "0X")
+		v, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			return n, err
+		}
+		if n == len(buf) {
+			return n, strconv.ErrRange
+		}
+		buf[n] = byte(v)
+		n++
+	}
+	return n, nil
+}
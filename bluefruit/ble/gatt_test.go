@@ -0,0 +1,171 @@
+package ble_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+)
+
+func TestHexBytes(t *testing.T) {
+	tests := []struct {
+		value []byte
+		want  string
+	}{
+		{nil, ""},
+		{[]byte{0}, "00"},
+		{[]byte{0x01, 0xAB, 0xFF}, "01-AB-FF"},
+	}
+	for _, tt := range tests {
+		if got := ble.HexBytes(tt.value); got != tt.want {
+			t.Errorf("HexBytes(% X) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseHexBytes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []byte
+		err  bool
+	}{
+		{"", []byte{}, false},
+		{"7", []byte{0x07}, false},
+		{"01-AB-ff", []byte{0x01, 0xAB, 0xFF}, false},
+		{"0x01-0X02", []byte{0x01, 0x02}, false},
+		{"01-02-03-04", []byte{0x01, 0x02, 0x03, 0x04}, false},
+		{"01-02-03-04-05", []byte{0x01, 0x02, 0x03, 0x04}, true},
+		{"01--02", []byte{0x01}, true},
+		{"100", []byte{}, true},
+		{"0G", []byte{}, true},
+	}
+	for _, tt := range tests {
+		buf := make([]byte, 4)
+		n, err := ble.ParseHexBytes(tt.s, buf)
+		if (err != nil) != tt.err || !bytes.Equal(buf[:n], tt.want) {
+			t.Errorf("ParseHexBytes(%q) = % X, %v", tt.s, buf[:n], err)
+		}
+	}
+	// values round-trip
+	value := []byte{0x00, 0x10, 0x7F, 0x80, 0xFF}
+	buf := make([]byte, len(value))
+	if n, err := ble.ParseHexBytes(ble.HexBytes(value), buf); err != nil || !bytes.Equal(buf[:n], value) {
+		t.Errorf("round trip: % X, %v", buf[:n], err)
+	}
+}
+
+func TestGATTCommands(t *testing.T) {
+	mod, dev, _ := begin(t)
+	const (
+		addService = "AT+GATTADDSERVICE=UUID128=00-11-22-33-44-55-66-77-88-99-AA-BB-CC-DD-EE-FF"
+		addLevel   = "AT+GATTADDCHAR=UUID=0x2A19,PROPERTIES=0x12,MIN_LEN=1,MAX_LEN=1,DATATYPE=2,VALUE=64,DESCRIPTION=Level"
+		addData    = "AT+GATTADDCHAR=UUID128=00-11-22-33-44-55-66-77-88-99-AA-BB-CC-DD-EE-F0,PROPERTIES=0x08,MIN_LEN=0,MAX_LEN=20,DATATYPE=2"
+	)
+	mod.RespondOK("AT+GATTCLEAR")
+	mod.RespondOK(addService, "1")
+	mod.RespondOK(addLevel, "3")
+	mod.RespondOK(addData, "4")
+	mod.RespondOK("AT+GATTCHAR=3", "0x01-0x02")
+	mod.RespondOK("AT+GATTCHAR=3,0A-FF")
+
+	if err := dev.ClearGATT(); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := dev.AddService(ble.UUID128("00-11-22-33-44-55-66-77-88-99-AA-BB-CC-DD-EE-FF"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Index != 1 {
+		t.Errorf("got service index %d, want 1", svc.Index)
+	}
+	level, err := svc.AddCharacteristic(ble.Characteristic{
+		UUID:        ble.UUID16(0x2A19),
+		Properties:  ble.PropRead | ble.PropNotify,
+		MinLen:      1,
+		MaxLen:      1,
+		Description: "Level",
+	}, []byte{100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := svc.AddCharacteristic(ble.Characteristic{
+		UUID:       ble.UUID128("00-11-22-33-44-55-66-77-88-99-AA-BB-CC-DD-EE-F0"),
+		Properties: ble.PropWrite,
+		MaxLen:     20,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level.Index != 3 || data.Index != 4 {
+		t.Errorf("got characteristic indexes %d and %d, want 3 and 4", level.Index, data.Index)
+	}
+	if len(dev.Services()) != 1 || len(svc.Characteristics) != 2 {
+		t.Errorf("got %d services and %d characteristics", len(dev.Services()), len(svc.Characteristics))
+	}
+
+	buf := make([]byte, 4)
+	n, err := level.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], []byte{1, 2}) {
+		t.Errorf("read % X, %v", buf[:n], err)
+	}
+	if err := level.Write([]byte{0x0A, 0xFF}); err != nil {
+		t.Error(err)
+	}
+
+	want := []string{"AT+GATTCLEAR", addService, addLevel, addData, "AT+GATTCHAR=3", "AT+GATTCHAR=3,0A-FF"}
+	cmds := mod.Commands()
+	if len(cmds) != len(want) {
+		t.Fatalf("sent %q, want %q", cmds, want)
+	}
+	for i := range want {
+		if cmds[i] != want[i] {
+			t.Errorf("command %d: got %q, want %q", i, cmds[i], want[i])
+		}
+	}
+}
+
+func TestGATTErrors(t *testing.T) {
+	mod, dev, _ := begin(t)
+	mod.RespondOK("AT+GATTADDSERVICE=UUID=0x180F", "1")
+	svc, err := dev.AddService(ble.UUID16(0x180F))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := len(mod.Commands())
+	_, err = svc.AddCharacteristic(ble.Characteristic{UUID: ble.UUID16(0x2A19), Description: "Level, in percent"}, nil)
+	if err != ble.ErrInvalidDescription {
+		t.Errorf("got %v, want %v", err, ble.ErrInvalidDescription)
+	}
+	if len(mod.Commands()) != n {
+		t.Errorf("sent %q for an invalid description", mod.Commands()[n:])
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		err      error
+	}{
+		{"no index", "OK\r\n", ble.ErrNoIndex},
+		{"not a number", "one\r\nOK\r\n", nil},
+		{"index out of range", "256\r\nOK\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mod.Respond("AT+GATTADDSERVICE=UUID=0x1234", tt.response)
+			svc, err := dev.AddService(ble.UUID16(0x1234))
+			if err == nil || (tt.err != nil && err != tt.err) || svc != nil {
+				t.Errorf("got %v, %v", svc, err)
+			}
+		})
+	}
+
+	var atErr *ble.ATError
+	if _, err := dev.AddService(ble.UUID16(0x5678)); !errors.As(err, &atErr) {
+		t.Errorf("got %v, want an *ATError", err)
+	}
+	if len(dev.Services()) != 1 {
+		t.Errorf("got %d services, want 1", len(dev.Services()))
+	}
+}
@@ -0,0 +1,118 @@
+package ble
+
+import (
+	"errors"
+)
+
+// MaxProfiles is the number of centrals that Profiles can switch between
+const MaxProfiles = 4
+
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// ClearBonds deletes the bonding information of every central stored on the
+// module
+func (dev *SPIFriend) ClearBonds() error {
+	_, err := dev.Command("AT+GAPDELBONDS")
+	return err
+}
+
+// PeerAddress returns the address of the connected central
+func (dev *SPIFriend) PeerAddress() (Address, error) {
+	rsp, err := dev.Command("AT+BLEGETPEERADDR")
+	if err != nil {
+		return Address{}, err
+	}
+	return ParseAddress(string(rsp))
+}
+
+// Profiles switches between several centrals that are bonded with the module.
+// The Bluefruit firmware can delete its bonds but can neither list them nor
+// advertise to a particular central, so each profile remembers the address
+// of the first central to connect while it is selected, and Check disconnects
+// any other central.  Profiles are only kept in memory, and centrals that use
+// a rotating private address will not be recognized after it changes.
+type Profiles struct {
+	dev    *SPIFriend
+	peers  [MaxProfiles]Address
+	active uint8
+}
+
+func NewProfiles(dev *SPIFriend) *Profiles {
+	return &Profiles{dev: dev}
+}
+
+// Active returns the selected profile
+func (p *Profiles) Active() uint8 {
+	return p.active
+}
+
+// Peer returns the address of the central assigned to a profile, if any
+func (p *Profiles) Peer(profile uint8) (Address, bool) {
+	if profile >= MaxProfiles || p.peers[profile] == (Address{}) {
+		return Address{}, false
+	}
+	return p.peers[profile], true
+}
+
+// Select makes a profile active, disconnecting the current central and
+// restarting advertising so that the central for the profile can connect.  If
+// either fails the active profile is left as it was.
+func (p *Profiles) Select(profile uint8) error {
+	if profile >= MaxProfiles {
+		return ErrInvalidProfile
+	}
+	if profile == p.active {
+		return nil
+	}
+	if err := p.dev.Disconnect(); err != nil {
+		return err
+	}
+	if err := p.readvertise(); err != nil {
+		return err
+	}
+	p.active = profile
+	return nil
+}
+
+// Clear forgets the centrals of every profile and deletes the bonds stored on
+// the module, so that new centrals can be paired
+func (p *Profiles) Clear() error {
+	p.peers = [MaxProfiles]Address{}
+	if err := p.dev.Disconnect(); err != nil {
+		return err
+	}
+	if err := p.dev.ClearBonds(); err != nil {
+		return err
+	}
+	return p.readvertise()
+}
+
+// Check should be called when a central connects.  It assigns the central to
+// the active profile if the profile is free, and disconnects it if it belongs
+// to a different profile.
+func (p *Profiles) Check() error {
+	addr, err := p.dev.PeerAddress()
+	if err != nil {
+		return err
+	}
+	for i, peer := range p.peers {
+		if peer == addr && uint8(i) != p.active {
+			return p.dev.Disconnect()
+		}
+	}
+	switch p.peers[p.active] {
+	case addr:
+		return nil
+	case Address{}:
+		p.peers[p.active] = addr
+		return nil
+	}
+	return p.dev.Disconnect()
+}
+
+func (p *Profiles) readvertise() error {
+	if err := p.dev.StopAdvertising(); err != nil {
+		return err
+	}
+	return p.dev.StartAdvertising()
+}
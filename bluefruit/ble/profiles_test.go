@@ -0,0 +1,150 @@
+package ble_test
+
+import (
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+	"github.com/bgould/tinygo-model-m/bluefruit/ble/sim"
+)
+
+const (
+	peerA = "48:B2:26:E6:C1:1D"
+	peerB = "11:22:33:44:55:66"
+)
+
+func beginProfiles(t *testing.T) (*sim.Module, *ble.Profiles) {
+	mod, dev, _ := begin(t)
+	for _, c := range []string{"AT+GAPDISCONNECT", "AT+GAPSTOPADV", "AT+GAPSTARTADV", "AT+GAPDELBONDS"} {
+		mod.RespondOK(c)
+	}
+	return mod, ble.NewProfiles(dev)
+}
+
+// connect simulates a central connecting and returns the commands that Check
+// sent after reading its address
+func connect(t *testing.T, mod *sim.Module, p *ble.Profiles, peer string) []string {
+	mod.RespondOK("AT+BLEGETPEERADDR", peer)
+	n := len(mod.Commands())
+	if err := p.Check(); err != nil {
+		t.Fatal(err)
+	}
+	return mod.Commands()[n+1:]
+}
+
+func TestProfilesCheck(t *testing.T) {
+	mod, p := beginProfiles(t)
+
+	// the first central is assigned to the free active profile
+	if cmds := connect(t, mod, p, peerA); len(cmds) != 0 {
+		t.Errorf("sent %q for the first central", cmds)
+	}
+	if addr, ok := p.Peer(0); !ok || addr.String() != peerA {
+		t.Errorf("profile 0 has %s, %t", addr, ok)
+	}
+	// and stays connected when it reconnects
+	if cmds := connect(t, mod, p, peerA); len(cmds) != 0 {
+		t.Errorf("sent %q when the central reconnected", cmds)
+	}
+	// another central is disconnected from a profile that is taken
+	if cmds := connect(t, mod, p, peerB); len(cmds) != 1 || cmds[0] != "AT+GAPDISCONNECT" {
+		t.Errorf("sent %q for another central", cmds)
+	}
+	if _, ok := p.Peer(1); ok {
+		t.Error("another central was assigned to profile 1")
+	}
+
+	if err := p.Select(1); err != nil {
+		t.Fatal(err)
+	}
+	// the central of profile 0 is disconnected while profile 1 is active
+	if cmds := connect(t, mod, p, peerA); len(cmds) != 1 || cmds[0] != "AT+GAPDISCONNECT" {
+		t.Errorf("sent %q for the central of another profile", cmds)
+	}
+	if cmds := connect(t, mod, p, peerB); len(cmds) != 0 {
+		t.Errorf("sent %q for a new central", cmds)
+	}
+	if addr, ok := p.Peer(1); !ok || addr.String() != peerB {
+		t.Errorf("profile 1 has %s, %t", addr, ok)
+	}
+}
+
+func TestProfilesCheckError(t *testing.T) {
+	mod, p := beginProfiles(t)
+	mod.Respond("AT+BLEGETPEERADDR", "ERROR\r\n")
+	if err := p.Check(); err == nil {
+		t.Fatal("no error when the address could not be read")
+	}
+	if _, ok := p.Peer(0); ok {
+		t.Error("profile 0 was assigned")
+	}
+}
+
+func TestProfilesSelect(t *testing.T) {
+	mod, p := beginProfiles(t)
+	if err := p.Select(2); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AT+GAPDISCONNECT", "AT+GAPSTOPADV", "AT+GAPSTARTADV"}
+	if cmds := mod.Commands(); len(cmds) != len(want) || cmds[0] != want[0] || cmds[1] != want[1] || cmds[2] != want[2] {
+		t.Errorf("sent %q, want %q", cmds, want)
+	}
+	if p.Active() != 2 {
+		t.Errorf("active profile is %d, want 2", p.Active())
+	}
+
+	// selecting the active profile does nothing
+	n := len(mod.Commands())
+	if err := p.Select(2); err != nil || len(mod.Commands()) != n {
+		t.Errorf("reselecting sent %q, %v", mod.Commands()[n:], err)
+	}
+
+	if err := p.Select(ble.MaxProfiles); err != ble.ErrInvalidProfile {
+		t.Errorf("got %v, want %v", err, ble.ErrInvalidProfile)
+	}
+
+	// the active profile does not change if the module fails
+	for _, c := range []string{"AT+GAPDISCONNECT", "AT+GAPSTOPADV", "AT+GAPSTARTADV"} {
+		mod.Respond(c, "ERROR\r\n")
+		if err := p.Select(3); err == nil {
+			t.Errorf("%s failed without an error", c)
+		}
+		if p.Active() != 2 {
+			t.Errorf("%s failed: active profile is %d, want 2", c, p.Active())
+		}
+		mod.RespondOK(c)
+	}
+}
+
+func TestProfilesClear(t *testing.T) {
+	mod, p := beginProfiles(t)
+	connect(t, mod, p, peerA)
+	p.Select(1)
+	connect(t, mod, p, peerB)
+
+	n := len(mod.Commands())
+	if err := p.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"AT+GAPDISCONNECT", "AT+GAPDELBONDS", "AT+GAPSTOPADV", "AT+GAPSTARTADV"}
+	cmds := mod.Commands()[n:]
+	if len(cmds) != len(want) {
+		t.Fatalf("sent %q, want %q", cmds, want)
+	}
+	for i := range want {
+		if cmds[i] != want[i] {
+			t.Fatalf("sent %q, want %q", cmds, want)
+		}
+	}
+	for i := uint8(0); i < ble.MaxProfiles; i++ {
+		if _, ok := p.Peer(i); ok {
+			t.Errorf("profile %d was not cleared", i)
+		}
+	}
+	// the active profile is kept and takes the next central
+	if cmds := connect(t, mod, p, peerA); len(cmds) != 0 || p.Active() != 1 {
+		t.Errorf("sent %q, active profile %d", cmds, p.Active())
+	}
+	if addr, ok := p.Peer(1); !ok || addr.String() != peerA {
+		t.Errorf("profile 1 has %s, %t", addr, ok)
+	}
+}
@@ -0,0 +1,278 @@
+// Package sim implements a ble.Transport that simulates a Bluefruit LE SPI
+// Friend in memory.  It decodes the SDEP packets written by the driver,
+// answers AT commands with scripted responses, passes BLE UART data to and
+// from the test, and can inject the 0xFE (not ready) and 0xFF (read overflow)
+// bytes that the module sends when it is busy.
+package sim
+
+import (
+	"strings"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+)
+
+type state uint8
+
+const (
+	stateIdle state = iota
+	stateWriting
+	stateReading
+	stateDiscard
+)
+
+// Module is a simulated Bluefruit module.  Commands without a scripted
+// response are answered with ERROR.
+type Module struct {
+	responses map[string]string
+	errors    map[string]uint16
+	commands  []string
+
+	selected bool
+	state    state
+	rx       []byte
+	cmd      []byte
+	tx       [][]byte
+	pos      int
+
+	uartRx []byte
+	uartTx []byte
+
+	notReady int
+	overflow int
+	resets   int
+}
+
+var _ ble.Transport = (*Module)(nil)
+
+func New() *Module {
+	return &Module{responses: make(map[string]string), errors: make(map[string]uint16)}
+}
+
+// Respond sets the raw response to an AT command, including the status line
+func (mod *Module) Respond(command string, response string) {
+	mod.responses[command] = response
+}
+
+// RespondError makes the module answer an AT command with an SDEP error
+// message with the specified error ID
+func (mod *Module) RespondError(command string, id uint16) {
+	mod.errors[command] = id
+}
+
+// RespondOK sets the response to an AT command to the lines of body followed
+// by OK
+func (mod *Module) RespondOK(command string, body ...string) {
+	var b strings.Builder
+	for _, line := range body {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("OK\r\n")
+	mod.Respond(command, b.String())
+}
+
+// SendUART queues data from the central for the host to read over BLE UART
+func (mod *Module) SendUART(p []byte) {
+	mod.uartTx = append(mod.uartTx, p...)
+}
+
+// ReceivedUART returns the data that the host has written over BLE UART
+func (mod *Module) ReceivedUART() []byte {
+	return mod.uartRx
+}
+
+// Commands returns the AT commands received so far, in order
+func (mod *Module) Commands() []string {
+	return mod.commands
+}
+
+// Resets returns the number of times the module has been reset, either with
+// the reset line or with the SDEP initialize command
+func (mod *Module) Resets() int {
+	return mod.resets
+}
+
+// InjectNotReady makes the module answer the first byte of the next n
+// transactions with 0xFE, as it does when it is not ready
+func (mod *Module) InjectNotReady(n int) {
+	mod.notReady += n
+}
+
+// InjectOverflow makes the module answer the next n attempts to read a packet
+// with 0xFF, as it does when the host reads faster than it can respond
+func (mod *Module) InjectOverflow(n int) {
+	mod.overflow += n
+}
+
+// Pending returns the number of packets waiting to be read by the host
+func (mod *Module) Pending() int {
+	return len(mod.tx)
+}
+
+func (mod *Module) Configure() error {
+	return nil
+}
+
+func (mod *Module) Transfer(w byte) (byte, error) {
+	return mod.exchange(w), nil
+}
+
+func (mod *Module) Tx(w, r []byte) error {
+	n := len(w)
+	if len(r) > n {
+		n = len(r)
+	}
+	for i := 0; i < n; i++ {
+		b := byte(0xFF)
+		if i < len(w) {
+			b = w[i]
+		}
+		b = mod.exchange(b)
+		if i < len(r) {
+			r[i] = b
+		}
+	}
+	return nil
+}
+
+// Select starts a transaction when selected is true, and ends it otherwise;
+// a command packet is handled at the end of the transaction that wrote it.
+func (mod *Module) Select(selected bool) {
+	if selected == mod.selected {
+		return
+	}
+	mod.selected = selected
+	if !selected && mod.state == stateWriting {
+		mod.receive(mod.rx)
+	}
+	mod.state = stateIdle
+	mod.rx = mod.rx[:0]
+}
+
+func (mod *Module) IRQ() bool {
+	return len(mod.tx) > 0
+}
+
+func (mod *Module) Reset(asserted bool) bool {
+	if asserted {
+		mod.reset()
+	}
+	return true
+}
+
+func (mod *Module) reset() {
+	mod.resets++
+	mod.cmd = mod.cmd[:0]
+	mod.tx = nil
+	mod.pos = 0
+}
+
+// exchange handles a byte written by the host and returns the byte that the
+// module writes at the same time
+func (mod *Module) exchange(w byte) byte {
+	if !mod.selected {
+		return 0xFF
+	}
+	switch mod.state {
+	case stateIdle:
+		if mod.notReady > 0 {
+			mod.notReady--
+			mod.state = stateDiscard
+			return sdep.ErrSlaveDeviceNotReady
+		}
+		if w == sdep.MsgTypeCommand {
+			mod.state = stateWriting
+			mod.rx = append(mod.rx, w)
+			return 0xFF
+		}
+		if mod.overflow > 0 {
+			mod.overflow--
+			mod.state = stateDiscard
+			return sdep.ErrSlaveDeviceReadOverflow
+		}
+		if len(mod.tx) == 0 {
+			return sdep.ErrSlaveDeviceNotReady
+		}
+		mod.state = stateReading
+		mod.pos = 0
+		return mod.read()
+	case stateWriting:
+		mod.rx = append(mod.rx, w)
+		return 0xFF
+	case stateReading:
+		return mod.read()
+	}
+	return 0xFF
+}
+
+// read returns the next byte of the packet being read, and moves on to the
+// next packet once the last byte has been read
+func (mod *Module) read() byte {
+	pkt := mod.tx[0]
+	b := pkt[mod.pos]
+	mod.pos++
+	if mod.pos == len(pkt) {
+		mod.tx = mod.tx[1:]
+		mod.pos = 0
+		mod.state = stateIdle
+	}
+	return b
+}
+
+// receive handles a command packet written by the host
+func (mod *Module) receive(pkt []byte) {
+	var msg sdep.Message
+	if _, err := msg.Unmarshal(pkt); err != nil {
+		return
+	}
+	if msg.Header.ID == sdep.CmdTypeInitialize {
+		mod.reset()
+		return
+	}
+	mod.cmd = append(mod.cmd, msg.GetPayload()...)
+	if msg.Header.HasMoreData() {
+		return
+	}
+	data := mod.cmd
+	mod.cmd = mod.cmd[:0]
+	switch msg.Header.ID {
+	case sdep.CmdTypeATWrapper:
+		command := string(data)
+		mod.commands = append(mod.commands, command)
+		mod.respond(command)
+	case sdep.CmdTypeBLEUARTTx:
+		mod.uartRx = append(mod.uartRx, data...)
+		mod.Queue(sdep.MsgTypeResponse, sdep.CmdTypeBLEUARTTx, nil)
+	case sdep.CmdTypeBLEUARTRx:
+		mod.Queue(sdep.MsgTypeResponse, sdep.CmdTypeBLEUARTRx, mod.uartTx)
+		mod.uartTx = nil
+	}
+}
+
+func (mod *Module) respond(command string) {
+	if id, ok := mod.errors[command]; ok {
+		mod.Queue(sdep.MsgTypeError, id, nil)
+		return
+	}
+	rsp, ok := mod.responses[command]
+	if !ok {
+		rsp = "ERROR\r\n"
+	}
+	mod.Queue(sdep.MsgTypeResponse, sdep.CmdTypeATWrapper, []byte(rsp))
+}
+
+// Queue splits payload into packets of the specified type and ID for the host
+// to read, setting the more data bit on all but the last one
+func (mod *Module) Queue(typ uint8, id uint16, payload []byte) {
+	var msg sdep.Message
+	for {
+		payload = sdep.Fragment(&msg, typ, id, payload)
+		pkt := make([]byte, sdep.MaxPacketSize)
+		n, _ := msg.MarshalTo(pkt)
+		mod.tx = append(mod.tx, pkt[:n])
+		if len(payload) == 0 {
+			return
+		}
+	}
+}
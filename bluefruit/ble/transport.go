@@ -0,0 +1,26 @@
+package ble
+
+// Transport is the connection to the module: a byte-wide SPI bus along with
+// the chip select, IRQ and reset lines.  SPITransport is the implementation
+// for the machine package; the sim package has one that runs off-device.
+type Transport interface {
+	// Configure sets up the lines; it is called by SPIFriend.Begin
+	Configure() error
+
+	// Transfer writes a byte and returns the byte read at the same time
+	Transfer(w byte) (byte, error)
+
+	// Tx writes w while reading into r; either one can be nil, in which case
+	// 0xFF is written or the bytes read are discarded
+	Tx(w, r []byte) error
+
+	// Select drives chip select low when selected is true, and high otherwise
+	Select(selected bool)
+
+	// IRQ returns true while the module has data to be read
+	IRQ() bool
+
+	// Reset drives the reset line low when asserted is true, and high
+	// otherwise; it returns false if there is no reset line
+	Reset(asserted bool) bool
+}
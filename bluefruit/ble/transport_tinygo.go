@@ -0,0 +1,62 @@
+//go:build tinygo
+// +build tinygo
+
+package ble
+
+import (
+	m "machine"
+)
+
+// SPITransport connects to the module with an SPI bus and pins from the
+// machine package
+type SPITransport struct {
+	bus *m.SPI
+	cs  m.Pin
+	irq m.Pin
+	rst m.Pin
+}
+
+// NewSPITransport returns a transport using the specified bus and pins; rst
+// can be m.NoPin if the reset line is not wired.
+func NewSPITransport(bus *m.SPI, cs m.Pin, irq m.Pin, rst m.Pin) *SPITransport {
+	return &SPITransport{bus: bus, cs: cs, irq: irq, rst: rst}
+}
+
+func NewSPIFriend(bus *m.SPI, cs m.Pin, irq m.Pin, rst m.Pin) *SPIFriend {
+	return New(NewSPITransport(bus, cs, irq, rst))
+}
+
+func (t *SPITransport) Configure() error {
+	t.irq.Configure(m.PinConfig{Mode: m.PinInput})
+	t.cs.Configure(m.PinConfig{Mode: m.PinOutput})
+	t.cs.High()
+	if t.rst != m.NoPin {
+		t.rst.Configure(m.PinConfig{Mode: m.PinOutput})
+		t.rst.High()
+	}
+	return nil
+}
+
+func (t *SPITransport) Transfer(w byte) (byte, error) {
+	return t.bus.Transfer(w)
+}
+
+func (t *SPITransport) Tx(w, r []byte) error {
+	return t.bus.Tx(w, r)
+}
+
+func (t *SPITransport) Select(selected bool) {
+	t.cs.Set(!selected)
+}
+
+func (t *SPITransport) IRQ() bool {
+	return t.irq.Get()
+}
+
+func (t *SPITransport) Reset(asserted bool) bool {
+	if t.rst == m.NoPin {
+		return false
+	}
+	t.rst.Set(!asserted)
+	return true
+}
@@ -0,0 +1,72 @@
+package ble
+
+import (
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+)
+
+// UARTBufferSize is the number of bytes received over BLE UART that can be
+// held until they are read, which is enough for the largest response that the
+// module can send
+const UARTBufferSize = ResponseBufferSize
+
+// UART reads and writes data over the Nordic UART service, which is available
+// alongside the HID keyboard service.  Read does not block; it returns
+// ErrNoData when nothing has been received.
+type UART struct {
+	dev *SPIFriend
+	buf [UARTBufferSize]byte
+	pos int
+	end int
+}
+
+// UART returns an io.ReadWriter for the BLE UART service
+func (dev *SPIFriend) UART() *UART {
+	return &UART{dev: dev}
+}
+
+// Buffered returns the number of bytes that can be read without polling the
+// module
+func (uart *UART) Buffered() int {
+	return uart.end - uart.pos
+}
+
+// Read copies received data into p, polling the module if nothing is
+// buffered, or returns ErrNoData if the module has not received anything
+func (uart *UART) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if uart.Buffered() == 0 {
+		if err = uart.fill(); err != nil {
+			return 0, err
+		}
+		if uart.Buffered() == 0 {
+			return 0, ErrNoData
+		}
+	}
+	n = copy(p, uart.buf[uart.pos:uart.end])
+	uart.pos += n
+	return n, nil
+}
+
+// fill reads any data that the module has received
+func (uart *UART) fill() error {
+	rsp, err := uart.dev.transact(sdep.CmdTypeBLEUARTRx, nil)
+	if err != nil {
+		return err
+	}
+	uart.pos = 0
+	uart.end = copy(uart.buf[:], rsp)
+	return nil
+}
+
+// Write sends p to the connected central
+func (uart *UART) Write(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err = uart.dev.transact(sdep.CmdTypeBLEUARTTx, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
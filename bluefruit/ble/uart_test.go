@@ -0,0 +1,83 @@
+package ble_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/ble"
+	"github.com/bgould/tinygo-model-m/bluefruit/ble/sim"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+func TestUARTWrite(t *testing.T) {
+	mod := sim.New()
+	bus := &packetCounter{Module: mod}
+	dev := ble.New(bus)
+	if err := dev.Begin(ble.SPIFriendConfig{Clock: timer.NewFake()}); err != nil {
+		t.Fatal(err)
+	}
+	bus.packets = 0
+	uart := dev.UART()
+	// 40 bytes take 3 packets of at most 16 bytes
+	data := []byte(strings.Repeat("0123456789", 4))
+	n, err := uart.Write(data)
+	if err != nil || n != len(data) {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if bus.packets != 3 {
+		t.Errorf("sent %d packets, want 3", bus.packets)
+	}
+	if got := mod.ReceivedUART(); !bytes.Equal(got, data) {
+		t.Errorf("module received %q, want %q", got, data)
+	}
+}
+
+func TestUARTRead(t *testing.T) {
+	mod, dev, _ := begin(t)
+	uart := dev.UART()
+	buf := make([]byte, 4)
+
+	if n, err := uart.Read(buf); n != 0 || err != ble.ErrNoData {
+		t.Fatalf("Read with nothing received returned %d, %v", n, err)
+	}
+
+	// a read smaller than the data leaves the rest buffered
+	mod.SendUART([]byte("hello"))
+	n, err := uart.Read(buf)
+	if err != nil || string(buf[:n]) != "hell" {
+		t.Fatalf("got %q, %v", buf[:n], err)
+	}
+	if uart.Buffered() != 1 {
+		t.Fatalf("%d bytes buffered, want 1", uart.Buffered())
+	}
+	mod.SendUART([]byte("world"))
+	if n, err = uart.Read(buf); err != nil || string(buf[:n]) != "o" {
+		t.Fatalf("got %q, %v", buf[:n], err)
+	}
+	if n, err = uart.Read(buf); err != nil || string(buf[:n]) != "worl" {
+		t.Fatalf("got %q, %v", buf[:n], err)
+	}
+}
+
+func TestUARTReadLarge(t *testing.T) {
+	mod, dev, _ := begin(t)
+	uart := dev.UART()
+	data := []byte(strings.Repeat("0123456789abcdef", 64))
+	mod.SendUART(data)
+	var got []byte
+	buf := make([]byte, 100)
+	for {
+		n, err := uart.Read(buf)
+		if err == ble.ErrNoData {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, want all %d", len(got), len(data))
+	}
+}
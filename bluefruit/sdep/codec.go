@@ -0,0 +1,176 @@
+package sdep
+
+// HeaderSize is the number of bytes before the payload in a packet
+const HeaderSize = 4
+
+const (
+	moreDataBit = 1 << 7
+	lengthMask  = 0x1F
+)
+
+// length returns the length of the payload as it is in the header, which is
+// invalid if it is more than MaxPayloadSize
+func (header *Header) length() uint8 {
+	return header.Size & lengthMask
+}
+
+// Error is returned when a packet cannot be encoded or decoded
+type Error uint8
+
+const (
+	ErrPayloadTooLarge Error = iota + 1
+	ErrShortPacket
+	ErrInvalidType
+	ErrShortBuffer
+	ErrMessageTooLarge
+	ErrFragment
+)
+
+func (err Error) Error() string {
+	switch err {
+	case ErrPayloadTooLarge:
+		return "sdep: payload too large"
+	case ErrShortPacket:
+		return "sdep: short packet"
+	case ErrInvalidType:
+		return "sdep: invalid message type"
+	case ErrShortBuffer:
+		return "sdep: buffer too small"
+	case ErrMessageTooLarge:
+		return "sdep: message too large"
+	case ErrFragment:
+		return "sdep: fragment does not match message"
+	}
+	return "sdep: unknown error"
+}
+
+// IsValidType returns true for the four message types
+func IsValidType(typ uint8) bool {
+	switch typ {
+	case MsgTypeCommand, MsgTypeResponse, MsgTypeAlert, MsgTypeError:
+		return true
+	}
+	return false
+}
+
+// SetPayload copies the payload into the message and sets the length and the
+// more data bit in the header
+func (msg *Message) SetPayload(payload []byte, more bool) error {
+	if len(payload) > MaxPayloadSize {
+		return ErrPayloadTooLarge
+	}
+	msg.Header.Size = uint8(copy(msg.Payload[:], payload))
+	if more {
+		msg.Header.Size |= moreDataBit
+	}
+	return nil
+}
+
+// MarshalTo writes the packet to buf and returns the number of bytes written
+func (msg *Message) MarshalTo(buf []byte) (int, error) {
+	length := int(msg.Header.length())
+	if length > MaxPayloadSize {
+		return 0, ErrPayloadTooLarge
+	}
+	if len(buf) < HeaderSize+length {
+		return 0, ErrShortBuffer
+	}
+	buf[0] = msg.Header.Type
+	buf[1] = byte(msg.Header.ID)
+	buf[2] = byte(msg.Header.ID >> 8)
+	buf[3] = msg.Header.Size
+	copy(buf[HeaderSize:], msg.Payload[:length])
+	return HeaderSize + length, nil
+}
+
+// Unmarshal reads a packet from buf into the message and returns the number of
+// bytes used; it checks the type and that the length of the payload is valid.
+func (msg *Message) Unmarshal(buf []byte) (int, error) {
+	if len(buf) < HeaderSize {
+		return 0, ErrShortPacket
+	}
+	if !IsValidType(buf[0]) {
+		return 0, ErrInvalidType
+	}
+	header := Header{Type: buf[0], ID: uint16(buf[1]) | uint16(buf[2])<<8, Size: buf[3]}
+	length := int(header.length())
+	if length > MaxPayloadSize {
+		return 0, ErrPayloadTooLarge
+	}
+	if len(buf) < HeaderSize+length {
+		return 0, ErrShortPacket
+	}
+	msg.Header = header
+	copy(msg.Payload[:], buf[HeaderSize:HeaderSize+length])
+	return HeaderSize + length, nil
+}
+
+// Fragment fills msg with the first MaxPayloadSize bytes of payload, setting
+// the more data bit if there are bytes left over, and returns the rest.  An
+// empty payload results in a single packet without a payload.
+func Fragment(msg *Message, typ uint8, id uint16, payload []byte) (rest []byte) {
+	n := len(payload)
+	if n > MaxPayloadSize {
+		n = MaxPayloadSize
+	}
+	msg.Header.Type = typ
+	msg.Header.ID = id
+	msg.SetPayload(payload[:n], n < len(payload))
+	return payload[n:]
+}
+
+// Reassembler joins the payloads of the packets of a fragmented message in a
+// fixed buffer
+type Reassembler struct {
+	buf     []byte
+	n       int
+	header  Header
+	started bool
+	done    bool
+}
+
+func NewReassembler(buf []byte) *Reassembler {
+	return &Reassembler{buf: buf}
+}
+
+// Add appends the payload of a packet, and returns true once the packet
+// without the more data bit has been added.  If the payload does not fit, as
+// much of it as possible is kept and ErrMessageTooLarge is returned; a packet
+// with a different type or ID than the first one results in ErrFragment.
+func (r *Reassembler) Add(msg *Message) (done bool, err error) {
+	if r.done {
+		r.Reset()
+	}
+	if !r.started {
+		r.header = msg.Header
+		r.started = true
+	} else if msg.Header.Type != r.header.Type || msg.Header.ID != r.header.ID {
+		return false, ErrFragment
+	}
+	if msg.Header.length() > MaxPayloadSize {
+		return false, ErrPayloadTooLarge
+	}
+	payload := msg.GetPayload()
+	c := copy(r.buf[r.n:], payload)
+	r.n += c
+	r.done = !msg.Header.HasMoreData()
+	if c < len(payload) {
+		return r.done, ErrMessageTooLarge
+	}
+	return r.done, nil
+}
+
+// Header returns the header of the first packet of the message
+func (r *Reassembler) Header() Header {
+	return r.header
+}
+
+func (r *Reassembler) Bytes() []byte {
+	return r.buf[:r.n]
+}
+
+func (r *Reassembler) Reset() {
+	r.n = 0
+	r.started = false
+	r.done = false
+}
@@ -0,0 +1,248 @@
+package sdep_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/bluefruit/sdep"
+)
+
+func payload(n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(i + 1)
+	}
+	return p
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     uint8
+		id      uint16
+		payload []byte
+		more    bool
+	}{
+		{"empty command", sdep.MsgTypeCommand, sdep.CmdTypeInitialize, nil, false},
+		{"response", sdep.MsgTypeResponse, sdep.CmdTypeATWrapper, []byte("OK\r\n"), false},
+		{"full alert with more data", sdep.MsgTypeAlert, 0x1234, payload(sdep.MaxPayloadSize), true},
+		{"error", sdep.MsgTypeError, 0x0003, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg sdep.Message
+			msg.Header.Type, msg.Header.ID = tt.typ, tt.id
+			if err := msg.SetPayload(tt.payload, tt.more); err != nil {
+				t.Fatal(err)
+			}
+			var buf [sdep.MaxPacketSize]byte
+			n, err := msg.MarshalTo(buf[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != sdep.HeaderSize+len(tt.payload) {
+				t.Errorf("wrote %d bytes, want %d", n, sdep.HeaderSize+len(tt.payload))
+			}
+			if buf[0] != tt.typ || buf[1] != byte(tt.id) || buf[2] != byte(tt.id>>8) {
+				t.Errorf("got header % X", buf[:sdep.HeaderSize])
+			}
+			var got sdep.Message
+			m, err := got.Unmarshal(buf[:n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m != n || got.Header != msg.Header || !bytes.Equal(got.GetPayload(), tt.payload) {
+				t.Errorf("read %d bytes: %+v % X", m, got.Header, got.GetPayload())
+			}
+			if got.Header.HasMoreData() != tt.more {
+				t.Errorf("got more data %t, want %t", got.Header.HasMoreData(), tt.more)
+			}
+		})
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	var msg sdep.Message
+	if err := msg.SetPayload(payload(sdep.MaxPayloadSize+1), false); err != sdep.ErrPayloadTooLarge {
+		t.Errorf("SetPayload: got %v, want %v", err, sdep.ErrPayloadTooLarge)
+	}
+	msg.Header = sdep.Header{Type: sdep.MsgTypeCommand, Size: 4}
+	if _, err := msg.MarshalTo(make([]byte, sdep.HeaderSize+3)); err != sdep.ErrShortBuffer {
+		t.Errorf("MarshalTo: got %v, want %v", err, sdep.ErrShortBuffer)
+	}
+	msg.Header.Size = 17
+	if _, err := msg.MarshalTo(make([]byte, 32)); err != sdep.ErrPayloadTooLarge {
+		t.Errorf("MarshalTo: got %v, want %v", err, sdep.ErrPayloadTooLarge)
+	}
+
+	tests := []struct {
+		name string
+		pkt  []byte
+		err  error
+	}{
+		{"short header", []byte{sdep.MsgTypeResponse, 0x00, 0x0A}, sdep.ErrShortPacket},
+		{"invalid type", []byte{0x30, 0x00, 0x0A, 0x00}, sdep.ErrInvalidType},
+		{"length over 16", append([]byte{sdep.MsgTypeResponse, 0x00, 0x0A, 17}, payload(17)...), sdep.ErrPayloadTooLarge},
+		{"short payload", []byte{sdep.MsgTypeResponse, 0x00, 0x0A, 4, 'O', 'K'}, sdep.ErrShortPacket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := msg.Unmarshal(tt.pkt); err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGetLength(t *testing.T) {
+	for size, want := range map[uint8]uint8{
+		0x00: 0, 0x10: 16, 0x90: 16, 0x85: 5, 0x11: 16, 0x1F: 16, 0xFF: 16,
+	} {
+		header := sdep.Header{Size: size}
+		if got := header.GetLength(); got != want {
+			t.Errorf("size %02X: got length %d, want %d", size, got, want)
+		}
+	}
+	msg := sdep.Message{Header: sdep.Header{Size: 0x1F}}
+	if n := len(msg.GetPayload()); n != sdep.MaxPayloadSize {
+		t.Errorf("got payload of %d bytes for length 31", n)
+	}
+}
+
+func TestFragment(t *testing.T) {
+	tests := []struct {
+		size    int
+		lengths []int
+	}{
+		{0, []int{0}},
+		{1, []int{1}},
+		{16, []int{16}},
+		{17, []int{16, 1}},
+		{32, []int{16, 16}},
+		{40, []int{16, 16, 8}},
+	}
+	for _, tt := range tests {
+		p := payload(tt.size)
+		var msg sdep.Message
+		var got []byte
+		rest := p
+		for i, want := range tt.lengths {
+			rest = sdep.Fragment(&msg, sdep.MsgTypeCommand, sdep.CmdTypeATWrapper, rest)
+			last := i == len(tt.lengths)-1
+			if int(msg.Header.GetLength()) != want || msg.Header.HasMoreData() == last {
+				t.Errorf("%d bytes, packet %d: got length %d, more data %t",
+					tt.size, i, msg.Header.GetLength(), msg.Header.HasMoreData())
+			}
+			if msg.Header.Type != sdep.MsgTypeCommand || msg.Header.ID != sdep.CmdTypeATWrapper {
+				t.Errorf("%d bytes, packet %d: got header %+v", tt.size, i, msg.Header)
+			}
+			got = append(got, msg.GetPayload()...)
+			if last != (len(rest) == 0) {
+				t.Fatalf("%d bytes, packet %d: %d bytes left", tt.size, i, len(rest))
+			}
+		}
+		if !bytes.Equal(got, p) {
+			t.Errorf("%d bytes: reassembled % X", tt.size, got)
+		}
+	}
+}
+
+// fragments splits payload into the packets of a message
+func fragments(typ uint8, id uint16, payload []byte) []sdep.Message {
+	var msgs []sdep.Message
+	for {
+		var msg sdep.Message
+		payload = sdep.Fragment(&msg, typ, id, payload)
+		msgs = append(msgs, msg)
+		if len(payload) == 0 {
+			return msgs
+		}
+	}
+}
+
+func TestReassembler(t *testing.T) {
+	p := payload(40)
+	r := sdep.NewReassembler(make([]byte, 64))
+	msgs := fragments(sdep.MsgTypeResponse, sdep.CmdTypeATWrapper, p)
+	for i := range msgs {
+		done, err := r.Add(&msgs[i])
+		if err != nil || done != (i == len(msgs)-1) {
+			t.Fatalf("packet %d: done %t, err %v", i, done, err)
+		}
+	}
+	if !bytes.Equal(r.Bytes(), p) {
+		t.Errorf("got % X", r.Bytes())
+	}
+	if h := r.Header(); h.Type != sdep.MsgTypeResponse || h.ID != sdep.CmdTypeATWrapper {
+		t.Errorf("got header %+v", h)
+	}
+
+	// a new message starts once the last one is done
+	msgs = fragments(sdep.MsgTypeAlert, 0x0001, []byte("hi"))
+	if done, err := r.Add(&msgs[0]); !done || err != nil || string(r.Bytes()) != "hi" {
+		t.Errorf("second message: done %t, err %v, got %q", done, err, r.Bytes())
+	}
+}
+
+func TestReassemblerErrors(t *testing.T) {
+	t.Run("packet from another message", func(t *testing.T) {
+		r := sdep.NewReassembler(make([]byte, 64))
+		first := fragments(sdep.MsgTypeResponse, sdep.CmdTypeATWrapper, payload(20))
+		other := fragments(sdep.MsgTypeResponse, sdep.CmdTypeBLEUARTRx, payload(4))
+		alert := fragments(sdep.MsgTypeAlert, sdep.CmdTypeATWrapper, payload(4))
+		r.Add(&first[0])
+		if _, err := r.Add(&other[0]); err != sdep.ErrFragment {
+			t.Errorf("different ID: got %v, want %v", err, sdep.ErrFragment)
+		}
+		if _, err := r.Add(&alert[0]); err != sdep.ErrFragment {
+			t.Errorf("different type: got %v, want %v", err, sdep.ErrFragment)
+		}
+		// the message can still be completed
+		if done, err := r.Add(&first[1]); !done || err != nil || !bytes.Equal(r.Bytes(), payload(20)) {
+			t.Errorf("done %t, err %v, got % X", done, err, r.Bytes())
+		}
+	})
+	t.Run("message larger than the buffer", func(t *testing.T) {
+		r := sdep.NewReassembler(make([]byte, 20))
+		msgs := fragments(sdep.MsgTypeResponse, sdep.CmdTypeATWrapper, payload(40))
+		var errs []error
+		var done bool
+		for i := range msgs {
+			var err error
+			done, err = r.Add(&msgs[i])
+			errs = append(errs, err)
+		}
+		if errs[0] != nil || errs[1] != sdep.ErrMessageTooLarge || errs[2] != sdep.ErrMessageTooLarge {
+			t.Errorf("got errors %v", errs)
+		}
+		if !done || !bytes.Equal(r.Bytes(), payload(20)) {
+			t.Errorf("done %t, got % X", done, r.Bytes())
+		}
+	})
+	t.Run("length over 16", func(t *testing.T) {
+		r := sdep.NewReassembler(make([]byte, 64))
+		msg := sdep.Message{Header: sdep.Header{Type: sdep.MsgTypeResponse, Size: 20}}
+		if _, err := r.Add(&msg); err != sdep.ErrPayloadTooLarge {
+			t.Errorf("got %v, want %v", err, sdep.ErrPayloadTooLarge)
+		}
+	})
+}
+
+func TestError(t *testing.T) {
+	errs := []sdep.Error{
+		sdep.ErrPayloadTooLarge, sdep.ErrShortPacket, sdep.ErrInvalidType,
+		sdep.ErrShortBuffer, sdep.ErrMessageTooLarge, sdep.ErrFragment,
+	}
+	seen := make(map[string]bool)
+	for _, err := range errs {
+		var e error = err
+		s := e.Error()
+		if s == "sdep: unknown error" || seen[s] {
+			t.Errorf("error %d: %q", err, s)
+		}
+		seen[s] = true
+	}
+	if s := sdep.Error(0).Error(); s != "sdep: unknown error" {
+		t.Errorf("got %q for an unknown error", s)
+	}
+}
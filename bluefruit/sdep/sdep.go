@@ -33,8 +33,13 @@ func (header *Header) HasMoreData() bool {
 	return header.Size>>7 > 0
 }
 
+// GetLength returns the length of the payload, which is never more than
+// MaxPayloadSize even if the header claims a longer one
 func (header *Header) GetLength() uint8 {
-	return header.Size & 31
+	if length := header.length(); length < MaxPayloadSize {
+		return length
+	}
+	return MaxPayloadSize
 }
 
 type Message struct {
@@ -42,6 +47,8 @@ type Message struct {
 	Payload [MaxPayloadSize]byte
 }
 
+// GetPayload returns the payload, which is cut short if the length in the
+// header is more than MaxPayloadSize
 func (msg *Message) GetPayload() []byte {
 	return msg.Payload[:msg.Header.GetLength()]
 }
@@ -0,0 +1,144 @@
+package keyboard
+
+import (
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+type ActionKind uint8
+
+const (
+	ActNone ActionKind = iota
+	ActKey
+	ActLayerMomentary
+	ActLayerToggle
+	ActLayerOneShot
+	ActDefaultLayerSet
+	ActModTap
+	ActLayerTap
+)
+
+// Action describes what happens when one of the FN0-FN31 keycodes is pressed
+// or released; the Fn keycodes in a keymap are bound to actions by index with
+// Keyboard.WithActions, loosely following fn_actions[] in TMK.
+type Action struct {
+	Kind  ActionKind
+	Layer uint8
+	Mods  KeyboardModifier
+	Key   keycodes.Keycode
+}
+
+// ActionKey sends a regular keycode; Fn keycodes are ignored, since an action
+// that refers to another action could otherwise recurse without end
+func ActionKey(key keycodes.Keycode) Action {
+	return Action{Kind: ActKey, Key: key}
+}
+
+func ActionLayerMomentary(layer uint8) Action {
+	return Action{Kind: ActLayerMomentary, Layer: layer}
+}
+
+func ActionLayerToggle(layer uint8) Action {
+	return Action{Kind: ActLayerToggle, Layer: layer}
+}
+
+func ActionLayerOneShot(layer uint8) Action {
+	return Action{Kind: ActLayerOneShot, Layer: layer}
+}
+
+func ActionDefaultLayerSet(layer uint8) Action {
+	return Action{Kind: ActDefaultLayerSet, Layer: layer}
+}
+
+// ActionModTap sends the key when tapped and acts as the modifiers when held
+func ActionModTap(mods KeyboardModifier, key keycodes.Keycode) Action {
+	return Action{Kind: ActModTap, Mods: mods, Key: key}
+}
+
+// ActionLayerTap sends the key when tapped and turns on the layer when held
+func ActionLayerTap(layer uint8, key keycodes.Keycode) Action {
+	return Action{Kind: ActLayerTap, Layer: layer, Key: key}
+}
+
+func (action Action) IsTapHold() bool {
+	return action.Kind == ActModTap || action.Kind == ActLayerTap
+}
+
+const (
+	oneShotNone uint8 = iota
+	oneShotHeld
+	oneShotUsed
+	oneShotWaiting
+)
+
+func (kbd *Keyboard) WithActions(actions []Action) *Keyboard {
+	kbd.actions = actions
+	return kbd
+}
+
+func (kbd *Keyboard) actionFor(key keycodes.Keycode) Action {
+	if i := int(key - keycodes.FN0); key.IsFn() && i < len(kbd.actions) {
+		return kbd.actions[i]
+	}
+	return Action{}
+}
+
+func (kbd *Keyboard) processAction(action Action, ev Event) {
+	switch action.Kind {
+	case ActKey:
+		if !action.Key.IsFn() {
+			kbd.processKey(action.Key, ev)
+		}
+	case ActLayerMomentary:
+		if ev.Made {
+			kbd.LayerOn(action.Layer)
+		} else {
+			kbd.LayerOff(action.Layer)
+		}
+	case ActLayerToggle:
+		if ev.Made {
+			kbd.LayerToggle(action.Layer)
+		}
+	case ActLayerOneShot:
+		kbd.processOneShot(action.Layer, ev)
+	case ActDefaultLayerSet:
+		if ev.Made {
+			kbd.SetDefaultLayer(action.Layer)
+		}
+	case ActModTap, ActLayerTap:
+		kbd.processTapHold(action, ev)
+	}
+}
+
+// processOneShot turns on a layer for the next key press only.  If another key
+// is pressed while the one-shot key is still held, it acts as a momentary
+// layer switch instead.
+func (kbd *Keyboard) processOneShot(layer uint8, ev Event) {
+	if ev.Made {
+		kbd.oneShotLayer = layer
+		kbd.oneShotState = oneShotHeld
+		kbd.LayerOn(layer)
+		return
+	}
+	if kbd.oneShotLayer != layer {
+		return
+	}
+	switch kbd.oneShotState {
+	case oneShotHeld:
+		kbd.oneShotState = oneShotWaiting
+	case oneShotUsed:
+		kbd.oneShotState = oneShotNone
+		kbd.LayerOff(layer)
+	}
+}
+
+// clearOneShot is called after any other key press has been processed
+func (kbd *Keyboard) clearOneShot() {
+	switch kbd.oneShotState {
+	case oneShotHeld:
+		// used as a momentary switch; the layer goes off on release
+		kbd.oneShotState = oneShotUsed
+	case oneShotWaiting:
+		kbd.oneShotState = oneShotNone
+		kbd.LayerOff(kbd.oneShotLayer)
+	}
+}
@@ -0,0 +1,35 @@
+package keyboard_test
+
+import (
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+)
+
+func TestActionKey(t *testing.T) {
+	var (
+		posFn2 = keyboard.Pos{Row: 0, Col: 2}
+		posFn3 = keyboard.Pos{Row: 0, Col: 3}
+	)
+	r := newTestRig([]keyboard.Keymap{{
+		{keycodes.FN0, keycodes.FN1, keycodes.FN2, keycodes.FN3},
+	}},
+		sim.Tap(10, 10, posFn0),
+		sim.Tap(30, 10, posFn1),
+		sim.Tap(50, 10, posFn2),
+		sim.Tap(70, 10, posFn3),
+	)
+	r.kbd.WithActions([]keyboard.Action{
+		keyboard.ActionKey(keycodes.FN0), // refers to itself
+		keyboard.ActionKey(keycodes.FN2), // FN1 and FN2 refer to each other
+		keyboard.ActionKey(keycodes.FN1),
+		keyboard.ActionKey(keycodes.A),
+	})
+	r.run(100)
+	want := []keycodes.Keycode{keycodes.A, keycodes.NO}
+	if got := r.keys(); !equalKeys(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+package keyboard
+
+// Debouncer filters the raw rows read from the matrix on every scan into the
+// debounced ("cooked") rows that the keyboard sees.  The algorithms and their
+// names follow the debounce implementations in QMK.
+type Debouncer interface {
+	// Init is called by NewMatrix with the dimensions of the matrix
+	Init(rows, cols uint8)
+
+	// Debounce updates cooked from raw at the specified time in milliseconds
+	// and returns true if any of the cooked rows has changed
+	Debounce(raw []Row, cooked []Row, now uint32) bool
+}
+
+// SymDeferGlobal waits until there have been no changes anywhere in the matrix
+// for the debounce time, and then reports all of the changes at once.  This
+// is the simplest algorithm and uses the least memory, but chatter on any key
+// delays every other key.
+type SymDeferGlobal struct {
+	ms        uint8
+	last      []Row
+	debounce  bool
+	changedAt uint32
+}
+
+func NewSymDeferGlobal(ms uint8) *SymDeferGlobal {
+	return &SymDeferGlobal{ms: ms}
+}
+
+func (d *SymDeferGlobal) Init(rows, cols uint8) {
+	d.last = make([]Row, rows)
+}
+
+func (d *SymDeferGlobal) Debounce(raw []Row, cooked []Row, now uint32) (changed bool) {
+	for i, row := range raw {
+		if d.last[i] != row {
+			d.last[i] = row
+			d.debounce = true
+			d.changedAt = now
+		}
+	}
+	if d.debounce && now-d.changedAt >= uint32(d.ms) {
+		for i, row := range raw {
+			if cooked[i] != row {
+				cooked[i] = row
+				changed = true
+			}
+		}
+		d.debounce = false
+	}
+	return
+}
+
+// SymDeferPerKey reports a change in a key once it has been different from the
+// debounced state for the debounce time.  If the key goes back to its
+// debounced state in the meantime the change is discarded.
+type SymDeferPerKey struct {
+	ms    uint8
+	cols  uint8
+	since []uint16
+	timer []Row
+}
+
+func NewSymDeferPerKey(ms uint8) *SymDeferPerKey {
+	return &SymDeferPerKey{ms: ms}
+}
+
+func (d *SymDeferPerKey) Init(rows, cols uint8) {
+	d.cols = cols
+	d.since = make([]uint16, int(rows)*int(cols))
+	d.timer = make([]Row, rows)
+}
+
+func (d *SymDeferPerKey) Debounce(raw []Row, cooked []Row, now uint32) (changed bool) {
+	for i, row := range raw {
+		delta := row ^ cooked[i]
+		// stop the timers of keys that have gone back to their debounced state
+		d.timer[i] &= delta
+		if delta == 0 {
+			continue
+		}
+		for j := uint8(0); j < d.cols; j++ {
+			mask := Row(1) << j
+			if delta&mask == 0 {
+				continue
+			}
+			idx := i*int(d.cols) + int(j)
+			if d.timer[i]&mask == 0 {
+				d.timer[i] |= mask
+				d.since[idx] = uint16(now)
+			} else if uint16(now)-d.since[idx] >= uint16(d.ms) {
+				d.timer[i] &^= mask
+				cooked[i] ^= mask
+				changed = true
+			}
+		}
+	}
+	return
+}
+
+// SymEagerPerKey reports a change in a key immediately, and then ignores any
+// further changes in that key for the debounce time.  This has the lowest
+// latency but is susceptible to noise.
+type SymEagerPerKey struct {
+	ms    uint8
+	cols  uint8
+	since []uint16
+	timer []Row
+}
+
+func NewSymEagerPerKey(ms uint8) *SymEagerPerKey {
+	return &SymEagerPerKey{ms: ms}
+}
+
+func (d *SymEagerPerKey) Init(rows, cols uint8) {
+	d.cols = cols
+	d.since = make([]uint16, int(rows)*int(cols))
+	d.timer = make([]Row, rows)
+}
+
+func (d *SymEagerPerKey) Debounce(raw []Row, cooked []Row, now uint32) (changed bool) {
+	for i, row := range raw {
+		// expire the timers of keys that have waited out the debounce time
+		for j, timer := uint8(0), d.timer[i]; timer != 0 && j < d.cols; j++ {
+			mask := Row(1) << j
+			idx := i*int(d.cols) + int(j)
+			if timer&mask > 0 && uint16(now)-d.since[idx] >= uint16(d.ms) {
+				d.timer[i] &^= mask
+			}
+		}
+		delta := (row ^ cooked[i]) &^ d.timer[i]
+		if delta == 0 {
+			continue
+		}
+		for j := uint8(0); j < d.cols; j++ {
+			if delta&(1<<j) > 0 {
+				d.since[i*int(d.cols)+int(j)] = uint16(now)
+			}
+		}
+		d.timer[i] |= delta
+		cooked[i] ^= delta
+		changed = true
+	}
+	return
+}
+
+// SymEagerPerRow works like SymEagerPerKey but with one timer for each row,
+// which uses less memory at the cost of also ignoring changes in other keys
+// in the same row for the debounce time.
+type SymEagerPerRow struct {
+	ms    uint8
+	since []uint16
+	timer []bool
+}
+
+func NewSymEagerPerRow(ms uint8) *SymEagerPerRow {
+	return &SymEagerPerRow{ms: ms}
+}
+
+func (d *SymEagerPerRow) Init(rows, cols uint8) {
+	d.since = make([]uint16, rows)
+	d.timer = make([]bool, rows)
+}
+
+func (d *SymEagerPerRow) Debounce(raw []Row, cooked []Row, now uint32) (changed bool) {
+	for i, row := range raw {
+		if d.timer[i] {
+			if uint16(now)-d.since[i] < uint16(d.ms) {
+				continue
+			}
+			d.timer[i] = false
+		}
+		if row != cooked[i] {
+			cooked[i] = row
+			d.since[i] = uint16(now)
+			d.timer[i] = true
+			changed = true
+		}
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package keyboard_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+const debounceTestMS = 5
+
+// debounce scans a single key once per millisecond until the specified time
+// and returns the times at which its debounced state changed
+func debounce(d keyboard.Debouncer, until uint32, ops ...[]sim.Op) []string {
+	clock := timer.NewFake()
+	matrix := keyboard.NewMatrix(1, 1, sim.NewMatrix(1, clock, ops...), clock, d)
+	var changes []string
+	sim.Run(clock, until, func() {
+		if matrix.Scan() {
+			changes = append(changes, fmt.Sprintf("%d: %t", timer.Millis(clock), matrix.IsOn(0, 0)))
+		}
+	})
+	return changes
+}
+
+func TestDebounce(t *testing.T) {
+	pos := keyboard.Pos{}
+	var (
+		// pressed at 10 and held down for 20ms
+		held = [][]sim.Op{sim.Tap(10, 20, pos)}
+		// closed for 2ms at 10, shorter than the debounce time
+		glitch = [][]sim.Op{sim.Tap(10, 2, pos)}
+		// toggles every ms from 10 and settles down pressed at 14
+		chatter = [][]sim.Op{sim.Bounce(10, pos, true, 1, 4)}
+	)
+	tests := []struct {
+		name      string
+		debouncer func(ms uint8) keyboard.Debouncer
+		held      []string
+		glitch    []string
+		chatter   []string
+	}{
+		{
+			name:      "SymDeferGlobal",
+			debouncer: func(ms uint8) keyboard.Debouncer { return keyboard.NewSymDeferGlobal(ms) },
+			held:      []string{"15: true", "35: false"},
+			glitch:    nil,
+			chatter:   []string{"19: true"},
+		},
+		{
+			name:      "SymDeferPerKey",
+			debouncer: func(ms uint8) keyboard.Debouncer { return keyboard.NewSymDeferPerKey(ms) },
+			held:      []string{"15: true", "35: false"},
+			glitch:    nil,
+			chatter:   []string{"19: true"},
+		},
+		{
+			name:      "SymEagerPerKey",
+			debouncer: func(ms uint8) keyboard.Debouncer { return keyboard.NewSymEagerPerKey(ms) },
+			held:      []string{"10: true", "30: false"},
+			glitch:    []string{"10: true", "15: false"},
+			chatter:   []string{"10: true"},
+		},
+		{
+			name:      "SymEagerPerRow",
+			debouncer: func(ms uint8) keyboard.Debouncer { return keyboard.NewSymEagerPerRow(ms) },
+			held:      []string{"10: true", "30: false"},
+			glitch:    []string{"10: true", "15: false"},
+			chatter:   []string{"10: true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range []struct {
+				name string
+				ops  [][]sim.Op
+				want []string
+			}{
+				{"held", held, tt.held},
+				{"glitch", glitch, tt.glitch},
+				{"chatter", chatter, tt.chatter},
+			} {
+				got := debounce(tt.debouncer(debounceTestMS), 50, c.ops...)
+				if fmt.Sprint(got) != fmt.Sprint(c.want) {
+					t.Errorf("%s: got changes %q, want %q", c.name, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+// TestDebounceClock scans at irregular intervals to check that debouncing is
+// timed by the injected clock rather than by the number of scans
+func TestDebounceClock(t *testing.T) {
+	clock := timer.NewFake()
+	m := sim.NewMatrix(1, clock, sim.Tap(10, 20, keyboard.Pos{}))
+	matrix := keyboard.NewMatrix(1, 1, m, clock, keyboard.NewSymDeferGlobal(debounceTestMS))
+	for _, step := range []struct {
+		at      uint32
+		changed bool
+	}{
+		{10, false},
+		{11, false},
+		{14, false},
+		{15, true},
+		{31, false},
+		{40, true},
+	} {
+		clock.Set(time.Duration(step.at) * time.Millisecond)
+		if changed := matrix.Scan(); changed != step.changed {
+			t.Fatalf("scan at %d: changed = %t, want %t", step.at, changed, step.changed)
+		}
+	}
+	if matrix.IsOn(0, 0) {
+		t.Fatal("key still on after release")
+	}
+}
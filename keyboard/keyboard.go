@@ -3,10 +3,25 @@ package keyboard
 import (
 	"fmt"
 	"io"
+	"math/bits"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/timer"
 )
 
 type Host interface {
 	Send(report *Report)
+	SendConsumer(usage ConsumerUsage)
+	SendSystem(usage SystemUsage)
+	SendMouse(report *MouseReport)
+}
+
+// NKROHost is implemented by hosts that can also receive an NKRO report.  The
+// keyboard falls back to the boot report while BootProtocol returns true.
+type NKROHost interface {
+	Host
+	SendNKRO(report *NKROReport)
+	BootProtocol() bool
 }
 
 type Event struct {
@@ -30,22 +45,60 @@ type Keyboard struct {
 	layers  []Keymap
 	host    Host
 
-	leds   uint8
-	prev   []Row
-	ghost  []Row
-	debug  bool
-	report *Report
+	actions []Action
+	sources []uint8
+
+	layerState        LayerState
+	defaultLayerState LayerState
+	oneShotLayer      uint8
+	oneShotState      uint8
+
+	consumer ConsumerUsage
+	system   SystemUsage
+	mouse    mouseKeys
+	tapping  tapHold
+
+	events *EventQueue
+	clock  timer.Clock
+
+	leds    LED
+	ledHost LEDHost
+	ledHook func(leds LED)
+
+	profileHost ProfileHost
+
+	prev     []Row
+	ghost    []Row
+	deferred []Row
+	debug    bool
+	report   *Report
+
+	nkro     NKROReport
+	nkroHost NKROHost
 }
 
 func New(console Console, host Host, matrix *Matrix, layers []Keymap) *Keyboard {
+	ledHost, _ := host.(LEDHost)
+	profileHost, _ := host.(ProfileHost)
 	return &Keyboard{
 		console: console,
 		matrix:  matrix,
 		layers:  layers,
 		host:    host,
-		prev:    make([]Row, MatrixRows),
-		ghost:   make([]Row, MatrixRows),
+		ledHost: ledHost,
+		prev:    make([]Row, matrix.Rows()),
+		ghost:   make([]Row, matrix.Rows()),
+		sources: make([]uint8, int(matrix.Rows())*int(matrix.Cols())),
 		report:  NewReport().Keyboard(0),
+		events:  NewEventQueue(EventQueueSize),
+		clock:   timer.System,
+
+		defaultLayerState: 1,
+		profileHost:       profileHost,
+		deferred:          make([]Row, matrix.Rows()),
+
+		mouse:   mouseKeys{config: DefaultMouseKeyConfig()},
+		tapping: tapHold{config: DefaultTapHoldConfig()},
 	}
 }
 
@@ -54,11 +107,43 @@ func (kbd *Keyboard) WithDebug(dbg bool) *Keyboard {
 	return kbd
 }
 
+// WithClock replaces the clock used to timestamp events and time actions,
+// which allows the keyboard to be driven deterministically off-device.
+func (kbd *Keyboard) WithClock(clock timer.Clock) *Keyboard {
+	kbd.clock = clock
+	return kbd
+}
+
+func (kbd *Keyboard) WithEventQueue(size int) *Keyboard {
+	kbd.events = NewEventQueue(size)
+	return kbd
+}
+
+// WithNKRO enables n-key rollover reports if the host implements NKROHost
+func (kbd *Keyboard) WithNKRO(enabled bool) *Keyboard {
+	kbd.nkroHost = nil
+	if host, ok := kbd.host.(NKROHost); ok && enabled {
+		kbd.nkroHost = host
+	}
+	return kbd
+}
+
+func (kbd *Keyboard) Events() *EventQueue {
+	return kbd.events
+}
+
 func (kbd *Keyboard) Task() {
+	now := kbd.millis()
 	kbd.matrix.Scan()
 	for i, rows := uint8(0), kbd.matrix.Rows(); i < rows; i++ {
 		row := kbd.matrix.GetRow(i)
 		diff := row ^ kbd.prev[i]
+		// a change that was deferred because the queue was full and that has
+		// since been undone never reaches the queue, and neither does its undo
+		if lost := kbd.deferred[i] &^ diff; lost != 0 {
+			kbd.deferred[i] &^= lost
+			kbd.events.dropped += 2 * uint32(bits.OnesCount32(uint32(lost)))
+		}
 		if diff == 0 {
 			continue
 		}
@@ -73,26 +158,116 @@ func (kbd *Keyboard) Task() {
 				ev := Event{
 					Pos:  Pos{i, j},
 					Made: row&mask > 0,
+					Time: now,
+				}
+				if kbd.events.Full() {
+					// leave the change in the matrix to be picked up again
+					if kbd.debug && kbd.deferred[i]&mask == 0 {
+						fmt.Fprintf(kbd.console, "event => deferred, queue full\r\n")
+					}
+					kbd.deferred[i] |= mask
+					continue
 				}
-				kbd.processEvent(ev)
+				kbd.events.Push(ev)
+				kbd.deferred[i] &^= mask
 				kbd.prev[i] ^= mask
 			}
 		}
 	}
+	kbd.processEvents(now)
+	kbd.mouseKeysTask(now)
+	kbd.ledsTask()
+}
+
+// processEvents handles the queued events in order, stopping while a pending
+// tap-hold key cannot be decided yet
+func (kbd *Keyboard) processEvents(now uint32) {
+	for {
+		if kbd.tapping.pending {
+			decided, hold := kbd.tapping.decide(kbd.events, now)
+			if !decided {
+				return
+			}
+			kbd.resolveTapHold(hold)
+			continue
+		}
+		ev, ok := kbd.events.Pop()
+		if !ok {
+			return
+		}
+		kbd.processEvent(ev)
+	}
 }
 
 func (kbd *Keyboard) processEvent(ev Event) {
-	key := kbd.layers[0].KeyAt(ev.Pos)
+	kbd.tapping.interrupt(ev.Pos)
+	layer := kbd.sourceLayer(ev)
+	key := kbd.layers[layer].KeyAt(ev.Pos)
 	if kbd.debug {
 		fmt.Fprintf(kbd.console,
-			"event => code: %X%X, made: %t, usb: %02X, mod: %t, key: %t\r\n",
-			ev.Pos.Row, ev.Pos.Col, ev.Made, key, key.IsModifier(), key.IsKey(),
+			"event => code: %X%X, made: %t, layer: %d, usb: %02X, key: %s\r\n",
+			ev.Pos.Row, ev.Pos.Col, ev.Made, layer, uint8(key), key.String(),
 		)
 	}
+	kbd.processKey(key, ev)
+	if ev.Made && kbd.actionFor(key).Kind != ActLayerOneShot {
+		kbd.clearOneShot()
+	}
+}
+
+func (kbd *Keyboard) processKey(key keycodes.Keycode, ev Event) {
+	switch {
+	case key.IsFn():
+		kbd.processAction(kbd.actionFor(key), ev)
+		return
+	case key.IsConsumer():
+		kbd.processConsumer(ConsumerUsageFor(key), ev)
+		return
+	case key.IsSystem():
+		kbd.processSystem(SystemUsageFor(key), ev)
+		return
+	case key.IsMouseKey():
+		kbd.processMouseKey(key, ev)
+		return
+	case key.IsProfile():
+		kbd.processProfile(key, ev)
+		return
+	}
 	if ev.Made {
 		kbd.report.Make(key)
+		kbd.nkro.Make(key)
 	} else {
 		kbd.report.Break(key)
+		kbd.nkro.Break(key)
+		if kbd.report.IsRollOver() {
+			kbd.report.FromNKRO(&kbd.nkro)
+		}
+	}
+	kbd.sendReport()
+}
+
+// setMods adds or removes modifiers that are not tied to a modifier key, such
+// as the hold of a mod-tap key, in both the boot and the NKRO report
+func (kbd *Keyboard) setMods(mods KeyboardModifier, on bool) {
+	if on {
+		kbd.report[0] |= byte(mods)
+		kbd.nkro[nkroModByte] |= byte(mods)
+	} else {
+		kbd.report[0] &^= byte(mods)
+		kbd.nkro[nkroModByte] &^= byte(mods)
+	}
+	kbd.sendReport()
+}
+
+// sendReport sends the NKRO report if it is enabled and the host is using the
+// report protocol, and the boot report otherwise
+func (kbd *Keyboard) sendReport() {
+	if kbd.nkroHost != nil && !kbd.nkroHost.BootProtocol() {
+		if kbd.debug {
+			fmt.Fprintf(kbd.console, "nkro => %s\r\n", kbd.nkro.String())
+		}
+		kbd.nkroHost.SendNKRO(&kbd.nkro)
+		return
 	}
 	if kbd.debug {
 		fmt.Fprintf(kbd.console, "report => %s\r\n", kbd.report.String())
@@ -100,6 +275,41 @@ func (kbd *Keyboard) processEvent(ev Event) {
 	kbd.host.Send(kbd.report)
 }
 
+// processConsumer sends the usage on press, and an empty report on release
+// unless another consumer key has been pressed in the meantime
+func (kbd *Keyboard) processConsumer(usage ConsumerUsage, ev Event) {
+	if !ev.Made {
+		if usage != kbd.consumer {
+			return
+		}
+		usage = ConsumerNone
+	}
+	kbd.consumer = usage
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "consumer => %04X\r\n", uint16(usage))
+	}
+	kbd.host.SendConsumer(usage)
+}
+
+func (kbd *Keyboard) processSystem(usage SystemUsage, ev Event) {
+	if !ev.Made {
+		if usage != kbd.system {
+			return
+		}
+		usage = SystemNone
+	}
+	kbd.system = usage
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "system => %04X\r\n", uint16(usage))
+	}
+	kbd.host.SendSystem(usage)
+}
+
+// millis returns a millisecond timestamp for timing key actions
+func (kbd *Keyboard) millis() uint32 {
+	return timer.Millis(kbd.clock)
+}
+
 func (kbd *Keyboard) debugMatrix() bool {
 	if kbd.debug {
 		kbd.matrix.Print(kbd.console)
@@ -107,3 +317,10 @@ func (kbd *Keyboard) debugMatrix() bool {
 	}
 	return false
 }
+
+func (kbd *Keyboard) debugLayers() {
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "layers => state: %08X, default: %08X\r\n",
+			uint32(kbd.layerState), uint32(kbd.defaultLayerState))
+	}
+}
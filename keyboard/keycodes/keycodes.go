@@ -49,6 +49,10 @@ func (code Keycode) IsFn() bool {
 	return (FN0 <= (code) && (code) <= FN31)
 }
 
+func (code Keycode) IsProfile() bool {
+	return (PROFILE1 <= (code) && (code) <= PROFILE_CLEAR)
+}
+
 func (code Keycode) IsMouseKey() bool {
 	return (MS_UP <= (code) && (code) <= MS_ACCEL2)
 }
@@ -354,7 +358,7 @@ const (
 	FN14
 	FN15
 
-	FN16 = iota + 0xD0
+	FN16
 	FN17
 	FN18
 	FN19
@@ -376,6 +380,15 @@ const (
 /* 0xE0-E7 for Modifiers. DO NOT USE. */
 /**************************************/
 
+/* Host profile selection, for hosts that can pair with several devices */
+const (
+	PROFILE1 = iota + 0xE8
+	PROFILE2
+	PROFILE3
+	PROFILE4
+	PROFILE_CLEAR /* 0xEC */
+)
+
 /* Mousekey */
 const (
 	MS_UP = iota + 0xF0
@@ -0,0 +1,296 @@
+package keycodes
+
+import "strconv"
+
+// names lists the name of every keycode constant, ordered by value.  The
+// first name for each value is the one returned by String, which is the
+// short TMK alias if there is one; 0x01 is TRNS rather than ROLL_OVER since
+// it is mostly seen in keymaps.  Keypad keys from 0xB0-0xDD share their values
+// with the special keycodes, which take precedence.
+var names = [...]struct {
+	name string
+	code Keycode
+}{
+	{"NO", NO},
+	{"TRNS", TRNS}, {"TRANSPARENT", TRANSPARENT}, {"ROLL_OVER", ROLL_OVER},
+	{"POST_FAIL", POST_FAIL},
+	{"UNDEFINED", UNDEFINED},
+	{"A", A},
+	{"B", B},
+	{"C", C},
+	{"D", D},
+	{"E", E},
+	{"F", F},
+	{"G", G},
+	{"H", H},
+	{"I", I},
+	{"J", J},
+	{"K", K},
+	{"L", L},
+	{"M", M},
+	{"N", N},
+	{"O", O},
+	{"P", P},
+	{"Q", Q},
+	{"R", R},
+	{"S", S},
+	{"T", T},
+	{"U", U},
+	{"V", V},
+	{"W", W},
+	{"X", X},
+	{"Y", Y},
+	{"Z", Z},
+	{"N1", N1},
+	{"N2", N2},
+	{"N3", N3},
+	{"N4", N4},
+	{"N5", N5},
+	{"N6", N6},
+	{"N7", N7},
+	{"N8", N8},
+	{"N9", N9},
+	{"N0", N0},
+	{"ENT", ENT}, {"ENTER", ENTER},
+	{"ESC", ESC}, {"ESCAPE", ESCAPE},
+	{"BSPC", BSPC}, {"BSPACE", BSPACE},
+	{"TAB", TAB},
+	{"SPC", SPC}, {"SPACE", SPACE},
+	{"MINS", MINS}, {"MINUS", MINUS},
+	{"EQL", EQL}, {"EQUAL", EQUAL},
+	{"LBRC", LBRC}, {"LBRACKET", LBRACKET},
+	{"RBRC", RBRC}, {"RBRACKET", RBRACKET},
+	{"BSLS", BSLS}, {"BSLASH", BSLASH},
+	{"NUHS", NUHS}, {"NONUS_HASH", NONUS_HASH},
+	{"SCLN", SCLN}, {"SCOLON", SCOLON},
+	{"QUOT", QUOT}, {"QUOTE", QUOTE},
+	{"GRV", GRV}, {"GRAVE", GRAVE}, {"ZKHK", ZKHK},
+	{"COMM", COMM}, {"COMMA", COMMA},
+	{"DOT", DOT},
+	{"SLSH", SLSH}, {"SLASH", SLASH},
+	{"CAPS", CAPS}, {"CAPSLOCK", CAPSLOCK}, {"CLCK", CLCK},
+	{"F1", F1},
+	{"F2", F2},
+	{"F3", F3},
+	{"F4", F4},
+	{"F5", F5},
+	{"F6", F6},
+	{"F7", F7},
+	{"F8", F8},
+	{"F9", F9},
+	{"F10", F10},
+	{"F11", F11},
+	{"F12", F12},
+	{"PSCR", PSCR}, {"PSCREEN", PSCREEN},
+	{"SLCK", SLCK}, {"SCROLLLOCK", SCROLLLOCK},
+	{"PAUS", PAUS}, {"PAUSE", PAUSE}, {"BRK", BRK},
+	{"INS", INS}, {"INSERT", INSERT},
+	{"HOME", HOME},
+	{"PGUP", PGUP},
+	{"DEL", DEL}, {"DELETE", DELETE},
+	{"END", END},
+	{"PGDN", PGDN}, {"PGDOWN", PGDOWN},
+	{"RGHT", RGHT}, {"RIGHT", RIGHT},
+	{"LEFT", LEFT},
+	{"DOWN", DOWN},
+	{"UP", UP},
+	{"NLCK", NLCK}, {"NUMLOCK", NUMLOCK},
+	{"PSLS", PSLS}, {"KP_SLASH", KP_SLASH},
+	{"PAST", PAST}, {"KP_ASTERISK", KP_ASTERISK},
+	{"PMNS", PMNS}, {"KP_MINUS", KP_MINUS},
+	{"PPLS", PPLS}, {"KP_PLUS", KP_PLUS},
+	{"PENT", PENT}, {"KP_ENTER", KP_ENTER},
+	{"P1", P1}, {"KP_1", KP_1},
+	{"P2", P2}, {"KP_2", KP_2},
+	{"P3", P3}, {"KP_3", KP_3},
+	{"P4", P4}, {"KP_4", KP_4},
+	{"P5", P5}, {"KP_5", KP_5},
+	{"P6", P6}, {"KP_6", KP_6},
+	{"P7", P7}, {"KP_7", KP_7},
+	{"P8", P8}, {"KP_8", KP_8},
+	{"P9", P9}, {"KP_9", KP_9},
+	{"P0", P0}, {"KP_0", KP_0},
+	{"PDOT", PDOT}, {"KP_DOT", KP_DOT},
+	{"NUBS", NUBS}, {"NONUS_BSLASH", NONUS_BSLASH},
+	{"APP", APP}, {"APPLICATION", APPLICATION},
+	{"POWER", POWER},
+	{"PEQL", PEQL}, {"KP_EQUAL", KP_EQUAL},
+	{"F13", F13},
+	{"F14", F14},
+	{"F15", F15},
+	{"F16", F16},
+	{"F17", F17},
+	{"F18", F18},
+	{"F19", F19},
+	{"F20", F20},
+	{"F21", F21},
+	{"F22", F22},
+	{"F23", F23},
+	{"F24", F24},
+	{"EXEC", EXEC}, {"EXECUTE", EXECUTE},
+	{"HELP", HELP},
+	{"MENU", MENU},
+	{"SLCT", SLCT}, {"SELECT", SELECT},
+	{"STOP", STOP},
+	{"AGIN", AGIN}, {"AGAIN", AGAIN},
+	{"UNDO", UNDO},
+	{"CUT", CUT},
+	{"COPY", COPY},
+	{"PSTE", PSTE}, {"PASTE", PASTE},
+	{"FIND", FIND},
+	{"_MUTE", _MUTE},
+	{"_VOLUP", _VOLUP},
+	{"_VOLDOWN", _VOLDOWN},
+	{"LCAP", LCAP}, {"LOCKING_CAPS", LOCKING_CAPS},
+	{"LNUM", LNUM}, {"LOCKING_NUM", LOCKING_NUM},
+	{"LSCR", LSCR}, {"LOCKING_SCROLL", LOCKING_SCROLL},
+	{"PCMM", PCMM}, {"KP_COMMA", KP_COMMA},
+	{"KP_EQUAL_AS400", KP_EQUAL_AS400},
+	{"RO", RO}, {"INT1", INT1},
+	{"KANA", KANA}, {"INT2", INT2},
+	{"JYEN", JYEN}, {"INT3", INT3}, {"JPY", JPY},
+	{"HENK", HENK}, {"INT4", INT4},
+	{"MHEN", MHEN}, {"INT5", INT5},
+	{"INT6", INT6},
+	{"INT7", INT7},
+	{"INT8", INT8},
+	{"INT9", INT9},
+	{"HAEN", HAEN}, {"LANG1", LANG1},
+	{"HANJ", HANJ}, {"LANG2", LANG2},
+	{"LANG3", LANG3},
+	{"LANG4", LANG4},
+	{"LANG5", LANG5},
+	{"LANG6", LANG6},
+	{"LANG7", LANG7},
+	{"LANG8", LANG8},
+	{"LANG9", LANG9},
+	{"ERAS", ERAS}, {"ALT_ERASE", ALT_ERASE},
+	{"SYSREQ", SYSREQ},
+	{"CANCEL", CANCEL},
+	{"CLR", CLR}, {"CLEAR", CLEAR},
+	{"PRIOR", PRIOR},
+	{"RETURN", RETURN},
+	{"SEPARATOR", SEPARATOR},
+	{"OUT", OUT},
+	{"OPER", OPER},
+	{"CLEAR_AGAIN", CLEAR_AGAIN},
+	{"CRSEL", CRSEL},
+	{"EXSEL", EXSEL},
+	{"PWR", PWR}, {"SYSTEM_POWER", SYSTEM_POWER},
+	{"SLEP", SLEP}, {"SYSTEM_SLEEP", SYSTEM_SLEEP},
+	{"WAKE", WAKE}, {"SYSTEM_WAKE", SYSTEM_WAKE},
+	{"MUTE", MUTE}, {"AUDIO_MUTE", AUDIO_MUTE},
+	{"VOLU", VOLU}, {"AUDIO_VOL_UP", AUDIO_VOL_UP},
+	{"VOLD", VOLD}, {"AUDIO_VOL_DOWN", AUDIO_VOL_DOWN},
+	{"MNXT", MNXT}, {"MEDIA_NEXT_TRACK", MEDIA_NEXT_TRACK},
+	{"MPRV", MPRV}, {"MEDIA_PREV_TRACK", MEDIA_PREV_TRACK},
+	{"MFFD", MFFD}, {"MEDIA_FAST_FORWARD", MEDIA_FAST_FORWARD},
+	{"MRWD", MRWD}, {"MEDIA_REWIND", MEDIA_REWIND},
+	{"MSTP", MSTP}, {"MEDIA_STOP", MEDIA_STOP},
+	{"MPLY", MPLY}, {"MEDIA_PLAY_PAUSE", MEDIA_PLAY_PAUSE}, {"KP_00", KP_00},
+	{"EJCT", EJCT}, {"MEDIA_EJECT", MEDIA_EJECT}, {"KP_000", KP_000},
+	{"MSEL", MSEL}, {"MEDIA_SELECT", MEDIA_SELECT}, {"THOUSANDS_SEPARATOR", THOUSANDS_SEPARATOR},
+	{"MAIL", MAIL}, {"DECIMAL_SEPARATOR", DECIMAL_SEPARATOR},
+	{"CALC", CALC}, {"CALCULATOR", CALCULATOR}, {"CURRENCY_UNIT", CURRENCY_UNIT},
+	{"MYCM", MYCM}, {"MY_COMPUTER", MY_COMPUTER}, {"CURRENCY_SUB_UNIT", CURRENCY_SUB_UNIT},
+	{"WSCH", WSCH}, {"WWW_SEARCH", WWW_SEARCH}, {"KP_LPAREN", KP_LPAREN},
+	{"WHOM", WHOM}, {"WWW_HOME", WWW_HOME}, {"KP_RPAREN", KP_RPAREN},
+	{"WBAK", WBAK}, {"WWW_BACK", WWW_BACK}, {"KP_LCBRACKET", KP_LCBRACKET},
+	{"WFWD", WFWD}, {"WWW_FORWARD", WWW_FORWARD}, {"KP_RCBRACKET", KP_RCBRACKET},
+	{"WSTP", WSTP}, {"WWW_STOP", WWW_STOP}, {"KP_TAB", KP_TAB},
+	{"WREF", WREF}, {"WWW_REFRESH", WWW_REFRESH}, {"KP_BSPACE", KP_BSPACE},
+	{"WFAV", WFAV}, {"WWW_FAVORITES", WWW_FAVORITES}, {"KP_A", KP_A},
+	{"KP_B", KP_B},
+	{"KP_C", KP_C},
+	{"BTLD", BTLD}, {"BOOTLOADER", BOOTLOADER}, {"KP_D", KP_D},
+	{"FN0", FN0}, {"KP_E", KP_E},
+	{"FN1", FN1}, {"KP_F", KP_F},
+	{"FN2", FN2}, {"KP_XOR", KP_XOR},
+	{"FN3", FN3}, {"KP_HAT", KP_HAT},
+	{"FN4", FN4}, {"KP_PERC", KP_PERC},
+	{"FN5", FN5}, {"KP_LT", KP_LT},
+	{"FN6", FN6}, {"KP_GT", KP_GT},
+	{"FN7", FN7}, {"KP_AND", KP_AND},
+	{"FN8", FN8}, {"KP_LAZYAND", KP_LAZYAND},
+	{"FN9", FN9}, {"KP_OR", KP_OR},
+	{"FN10", FN10}, {"KP_LAZYOR", KP_LAZYOR},
+	{"FN11", FN11}, {"KP_COLON", KP_COLON},
+	{"FN12", FN12}, {"KP_HASH", KP_HASH},
+	{"FN13", FN13}, {"KP_SPACE", KP_SPACE},
+	{"FN14", FN14}, {"KP_ATMARK", KP_ATMARK},
+	{"FN15", FN15}, {"KP_EXCLAMATION", KP_EXCLAMATION},
+	{"FN16", FN16}, {"KP_MEM_STORE", KP_MEM_STORE},
+	{"FN17", FN17}, {"KP_MEM_RECALL", KP_MEM_RECALL},
+	{"FN18", FN18}, {"KP_MEM_CLEAR", KP_MEM_CLEAR},
+	{"FN19", FN19}, {"KP_MEM_ADD", KP_MEM_ADD},
+	{"FN20", FN20}, {"KP_MEM_SUB", KP_MEM_SUB},
+	{"FN21", FN21}, {"KP_MEM_MUL", KP_MEM_MUL},
+	{"FN22", FN22}, {"KP_MEM_DIV", KP_MEM_DIV},
+	{"FN23", FN23}, {"KP_PLUS_MINUS", KP_PLUS_MINUS},
+	{"FN24", FN24}, {"KP_CLEAR", KP_CLEAR},
+	{"FN25", FN25}, {"KP_CLEAR_ENTRY", KP_CLEAR_ENTRY},
+	{"FN26", FN26}, {"KP_BINARY", KP_BINARY},
+	{"FN27", FN27}, {"KP_OCTAL", KP_OCTAL},
+	{"FN28", FN28}, {"KP_DECIMAL", KP_DECIMAL},
+	{"FN29", FN29}, {"KP_HEXADECIMAL", KP_HEXADECIMAL},
+	{"FN30", FN30},
+	{"FN31", FN31},
+	{"LCTL", LCTL}, {"LCTRL", LCTRL},
+	{"LSFT", LSFT}, {"LSHIFT", LSHIFT},
+	{"LALT", LALT},
+	{"LGUI", LGUI},
+	{"RCTL", RCTL}, {"RCTRL", RCTRL},
+	{"RSFT", RSFT}, {"RSHIFT", RSHIFT},
+	{"RALT", RALT},
+	{"RGUI", RGUI},
+	{"PROFILE1", PROFILE1},
+	{"PROFILE2", PROFILE2},
+	{"PROFILE3", PROFILE3},
+	{"PROFILE4", PROFILE4},
+	{"PROFILE_CLEAR", PROFILE_CLEAR},
+	{"MS_U", MS_U}, {"MS_UP", MS_UP},
+	{"MS_D", MS_D}, {"MS_DOWN", MS_DOWN},
+	{"MS_L", MS_L}, {"MS_LEFT", MS_LEFT},
+	{"MS_R", MS_R}, {"MS_RIGHT", MS_RIGHT},
+	{"BTN1", BTN1}, {"MS_BTN1", MS_BTN1},
+	{"BTN2", BTN2}, {"MS_BTN2", MS_BTN2},
+	{"BTN3", BTN3}, {"MS_BTN3", MS_BTN3},
+	{"BTN4", BTN4}, {"MS_BTN4", MS_BTN4},
+	{"BTN5", BTN5}, {"MS_BTN5", MS_BTN5},
+	{"WH_U", WH_U}, {"MS_WH_UP", MS_WH_UP},
+	{"WH_D", WH_D}, {"MS_WH_DOWN", MS_WH_DOWN},
+	{"WH_L", WH_L}, {"MS_WH_LEFT", MS_WH_LEFT},
+	{"WH_R", WH_R}, {"MS_WH_RIGHT", MS_WH_RIGHT},
+	{"ACL0", ACL0}, {"MS_ACCEL0", MS_ACCEL0},
+	{"ACL1", ACL1}, {"MS_ACCEL1", MS_ACCEL1},
+	{"ACL2", ACL2}, {"MS_ACCEL2", MS_ACCEL2},
+}
+
+// String returns the name of the keycode, or its value in hex if it does not
+// have one, in a form that Parse accepts
+func (code Keycode) String() string {
+	for i := range names {
+		if names[i].code == code {
+			return names[i].name
+		}
+	}
+	const digits = "0123456789ABCDEF"
+	return string([]byte{'0', 'x', digits[code>>4], digits[code&0xF]})
+}
+
+// Parse returns the keycode with the specified name, which can be either the
+// short or the long name of any of the keycode constants, e.g. BSPC or BSPACE,
+// or a value in hex such as 0xE8 for codes that do not have a name
+func Parse(name string) (Keycode, bool) {
+	for i := range names {
+		if names[i].name == name {
+			return names[i].code, true
+		}
+	}
+	if len(name) == 4 && name[0] == '0' && (name[1] == 'x' || name[1] == 'X') {
+		if v, err := strconv.ParseUint(name[2:], 16, 8); err == nil {
+			return Keycode(v), true
+		}
+	}
+	return NO, false
+}
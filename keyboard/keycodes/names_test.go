@@ -0,0 +1,96 @@
+package keycodes_test
+
+import (
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+func TestStringParseRoundTrip(t *testing.T) {
+	for i := 0; i <= 0xFF; i++ {
+		code := keycodes.Keycode(i)
+		name := code.String()
+		if got, ok := keycodes.Parse(name); !ok || got != code {
+			t.Errorf("Parse(%q) = %02X, %t; want %02X", name, uint8(got), ok, i)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		code  keycodes.Keycode
+		short string
+		long  string
+	}{
+		{keycodes.BSPC, "BSPC", "BSPACE"},
+		{keycodes.LBRC, "LBRC", "LBRACKET"},
+		{keycodes.PSLS, "PSLS", "KP_SLASH"},
+		{keycodes.P1, "P1", "KP_1"},
+		{keycodes.FN0, "FN0", "FN0"},
+		{keycodes.FN31, "FN31", "FN31"},
+		{keycodes.MS_UP, "MS_U", "MS_UP"},
+		{keycodes.MS_BTN1, "BTN1", "MS_BTN1"},
+		{keycodes.MS_WH_RIGHT, "WH_R", "MS_WH_RIGHT"},
+		{keycodes.MS_ACCEL2, "ACL2", "MS_ACCEL2"},
+		{keycodes.AUDIO_MUTE, "MUTE", "AUDIO_MUTE"},
+		{keycodes.MEDIA_PLAY_PAUSE, "MPLY", "MEDIA_PLAY_PAUSE"},
+		{keycodes.WWW_FAVORITES, "WFAV", "WWW_FAVORITES"},
+		{keycodes.SYSTEM_POWER, "PWR", "SYSTEM_POWER"},
+		{keycodes.LSFT, "LSFT", "LSHIFT"},
+	}
+	for _, tt := range tests {
+		if s := tt.code.String(); s != tt.short {
+			t.Errorf("%02X.String() = %q, want %q", uint8(tt.code), s, tt.short)
+		}
+		for _, name := range []string{tt.short, tt.long} {
+			if got, ok := keycodes.Parse(name); !ok || got != tt.code {
+				t.Errorf("Parse(%q) = %02X, %t; want %02X", name, uint8(got), ok, uint8(tt.code))
+			}
+		}
+	}
+}
+
+// TestKeypadOverlap checks that the keypad keys in 0xB0-0xDD, which share
+// their values with the media, Fn and mouse keys, still parse but are named
+// after the special keycodes
+func TestKeypadOverlap(t *testing.T) {
+	tests := []struct {
+		keypad string
+		name   string
+	}{
+		{"KP_00", "MPLY"},
+		{"KP_A", "WFAV"},
+		{"KP_E", "FN0"},
+		{"KP_HEXADECIMAL", "FN29"},
+	}
+	for _, tt := range tests {
+		code, ok := keycodes.Parse(tt.keypad)
+		if !ok {
+			t.Errorf("Parse(%q) failed", tt.keypad)
+			continue
+		}
+		if s := code.String(); s != tt.name {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.keypad, s, tt.name)
+		}
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		name string
+		code keycodes.Keycode
+		ok   bool
+	}{
+		{"0xE8", 0xE8, true},
+		{"0X04", keycodes.A, true},
+		{"0x4", keycodes.NO, false},
+		{"0xZZ", keycodes.NO, false},
+		{"NOPE", keycodes.NO, false},
+		{"", keycodes.NO, false},
+	}
+	for _, tt := range tests {
+		if code, ok := keycodes.Parse(tt.name); code != tt.code || ok != tt.ok {
+			t.Errorf("Parse(%q) = %02X, %t; want %02X, %t", tt.name, uint8(code), ok, uint8(tt.code), tt.ok)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package keymap parses keymaps written as text, so that a layout can be
+// changed without editing Go source.
+//
+// A keymap file has one or more layers, each starting with a line of the form
+// "layer <name>", followed by the names of the keycodes in the layer separated
+// by spaces or commas.  The names are those of the constants in the keycodes
+// package, e.g. ESC, F1, LBRC or LBRACKET, and text after a '#' is a comment.
+// Keys before the first "layer" line are an error.  The order of the keys is
+// up to the board; for the Model M it is the order of the parameters of
+// modelm.ANSI101Keymap.
+package keymap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+// Key is a keycode in a layer, along with its name and where it appears in
+// the text
+type Key struct {
+	Code keycodes.Keycode
+	Name string
+	Line int
+	Col  int
+}
+
+type Layer struct {
+	Name string
+	Line int
+	Keys []Key
+}
+
+// Keycodes returns the keycodes in the layer in the order they appear
+func (layer *Layer) Keycodes() []keycodes.Keycode {
+	codes := make([]keycodes.Keycode, len(layer.Keys))
+	for i, key := range layer.Keys {
+		codes[i] = key.Code
+	}
+	return codes
+}
+
+// Parse reads all of the layers from r; the error for an unknown name, or for
+// a key before the first layer, includes the line number.
+func Parse(r io.Reader) ([]Layer, error) {
+	var layers []Layer
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "layer" {
+			name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "layer"))
+			layers = append(layers, Layer{Name: name, Line: n})
+			continue
+		}
+		for col := 0; col < len(line); {
+			if isSeparator(line[col]) {
+				col++
+				continue
+			}
+			end := col
+			for end < len(line) && !isSeparator(line[end]) {
+				end++
+			}
+			name := line[col:end]
+			code, ok := keycodes.Parse(name)
+			if !ok {
+				return nil, fmt.Errorf("keymap: line %d: unknown keycode %q", n, name)
+			}
+			if len(layers) == 0 {
+				return nil, fmt.Errorf("keymap: line %d: keycode %q before the first layer", n, name)
+			}
+			layer := &layers[len(layers)-1]
+			layer.Keys = append(layer.Keys, Key{Code: code, Name: name, Line: n, Col: col})
+			col = end
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return layers, nil
+}
+
+func isSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == ',' || c == '\r'
+}
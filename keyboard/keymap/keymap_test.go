@@ -0,0 +1,80 @@
+package keymap_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/keymap"
+)
+
+func TestParse(t *testing.T) {
+	const text = `# comment
+layer base
+ESC, A  B   # trailing comment
+	LBRACKET,0x7F
+layer fn
+TRNS
+`
+	layers, err := keymap.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 2 {
+		t.Fatalf("got %d layers, want 2", len(layers))
+	}
+	base := layers[0]
+	if base.Name != "base" || base.Line != 2 {
+		t.Errorf("got layer %q at line %d, want %q at line 2", base.Name, base.Line, "base")
+	}
+	want := []keymap.Key{
+		{Code: keycodes.ESC, Name: "ESC", Line: 3, Col: 0},
+		{Code: keycodes.A, Name: "A", Line: 3, Col: 5},
+		{Code: keycodes.B, Name: "B", Line: 3, Col: 8},
+		{Code: keycodes.LBRC, Name: "LBRACKET", Line: 4, Col: 1},
+		{Code: keycodes.Keycode(0x7F), Name: "0x7F", Line: 4, Col: 10},
+	}
+	if len(base.Keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(base.Keys), len(want))
+	}
+	for i := range want {
+		if base.Keys[i] != want[i] {
+			t.Errorf("key %d: got %+v, want %+v", i, base.Keys[i], want[i])
+		}
+	}
+	if fn := layers[1]; fn.Name != "fn" || len(fn.Keys) != 1 || fn.Keys[0].Code != keycodes.TRNS {
+		t.Errorf("got layer %q with keys %+v", fn.Name, fn.Keys)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  string
+	}{
+		{
+			name: "unknown keycode",
+			text: "layer 0\nESC, NOPE\n",
+			err:  `keymap: line 2: unknown keycode "NOPE"`,
+		},
+		{
+			name: "keycode out of range",
+			text: "layer 0\n0x100\n",
+			err:  `keymap: line 2: unknown keycode "0x100"`,
+		},
+		{
+			name: "missing layer line",
+			text: "# no layer\nESC, A\nlayer 0\nB\n",
+			err:  `keymap: line 2: keycode "ESC" before the first layer`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := keymap.Parse(strings.NewReader(tt.text))
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
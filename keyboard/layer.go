@@ -0,0 +1,89 @@
+package keyboard
+
+import (
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+const MaxLayers = 32
+
+// LayerState is a bitmask of active layers; bit n set means layer n is on.
+type LayerState uint32
+
+//go:inline
+func (s LayerState) IsOn(layer uint8) bool {
+	return s&(1<<(layer&31)) > 0
+}
+
+// Highest returns the highest active layer in the state, or 0 if none is on.
+func (s LayerState) Highest() uint8 {
+	for i := int8(MaxLayers - 1); i >= 0; i-- {
+		if s.IsOn(uint8(i)) {
+			return uint8(i)
+		}
+	}
+	return 0
+}
+
+func (kbd *Keyboard) LayerState() LayerState {
+	return kbd.layerState
+}
+
+func (kbd *Keyboard) DefaultLayerState() LayerState {
+	return kbd.defaultLayerState
+}
+
+func (kbd *Keyboard) LayerOn(layer uint8) {
+	kbd.setLayerState(kbd.layerState | 1<<(layer&31))
+}
+
+func (kbd *Keyboard) LayerOff(layer uint8) {
+	kbd.setLayerState(kbd.layerState &^ (1 << (layer & 31)))
+}
+
+func (kbd *Keyboard) LayerToggle(layer uint8) {
+	kbd.setLayerState(kbd.layerState ^ 1<<(layer&31))
+}
+
+func (kbd *Keyboard) LayerClear() {
+	kbd.setLayerState(0)
+}
+
+func (kbd *Keyboard) SetDefaultLayer(layer uint8) {
+	kbd.defaultLayerState = 1 << (layer & 31)
+	kbd.debugLayers()
+}
+
+func (kbd *Keyboard) setLayerState(state LayerState) {
+	kbd.layerState = state
+	kbd.debugLayers()
+}
+
+// layerFor returns the highest active layer that has a non-transparent key at
+// the specified position, falling back to layer 0 when all of them are.
+func (kbd *Keyboard) layerFor(pos Pos) uint8 {
+	active := kbd.layerState | kbd.defaultLayerState
+	for i := int8(MaxLayers - 1); i >= 0; i-- {
+		layer := uint8(i)
+		if int(layer) >= len(kbd.layers) || !active.IsOn(layer) {
+			continue
+		}
+		if kbd.layers[layer].KeyAt(pos) != keycodes.TRNS {
+			return layer
+		}
+	}
+	return 0
+}
+
+// sourceLayer resolves the layer to use for an event.  Key presses are looked
+// up against the current layer state and the result is remembered, so that
+// the corresponding release is resolved against the same layer even if the
+// layer state has changed while the key was held down.
+func (kbd *Keyboard) sourceLayer(ev Event) uint8 {
+	idx := int(ev.Pos.Row)*int(kbd.matrix.Cols()) + int(ev.Pos.Col)
+	if ev.Made {
+		layer := kbd.layerFor(ev.Pos)
+		kbd.sources[idx] = layer
+		return layer
+	}
+	return kbd.sources[idx]
+}
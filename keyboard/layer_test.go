@@ -0,0 +1,158 @@
+package keyboard_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+// testRig runs a keyboard against a scripted matrix and records its reports
+type testRig struct {
+	clock  *timer.Fake
+	matrix *sim.Matrix
+	host   *sim.Recorder
+	kbd    *keyboard.Keyboard
+}
+
+func newTestRig(layers []keyboard.Keymap, ops ...[]sim.Op) *testRig {
+	clock := timer.NewFake()
+	rows, cols := uint8(len(layers[0])), uint8(len(layers[0][0]))
+	m := sim.NewMatrix(rows, clock, ops...)
+	host := sim.NewRecorder(clock)
+	matrix := keyboard.NewMatrix(rows, cols, m, clock, keyboard.NewSymDeferGlobal(0))
+	kbd := keyboard.New(&bytes.Buffer{}, host, matrix, layers).WithClock(clock)
+	return &testRig{clock: clock, matrix: m, host: host, kbd: kbd}
+}
+
+func (r *testRig) run(until uint32) {
+	sim.Run(r.clock, until, r.kbd.Task)
+}
+
+// keys returns the first key slot of each keyboard report
+func (r *testRig) keys() []keycodes.Keycode {
+	var keys []keycodes.Keycode
+	for _, e := range r.host.Entries() {
+		if e.Kind == sim.KeyboardEntry {
+			keys = append(keys, keycodes.Keycode(e.Report[2]))
+		}
+	}
+	return keys
+}
+
+func equalKeys(got, want []keycodes.Keycode) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	posFn0   = keyboard.Pos{Row: 0, Col: 0}
+	posFn1   = keyboard.Pos{Row: 0, Col: 1}
+	posShift = keyboard.Pos{Row: 1, Col: 0}
+	posA     = keyboard.Pos{Row: 1, Col: 1}
+)
+
+// layerTestLayers has FN0 and FN1 on the top row and LSFT and A on the bottom
+// row; A is B on layer 1
+func layerTestLayers() []keyboard.Keymap {
+	return []keyboard.Keymap{
+		{
+			{keycodes.FN0, keycodes.FN1},
+			{keycodes.LSFT, keycodes.A},
+		},
+		{
+			{keycodes.TRNS, keycodes.TRNS},
+			{keycodes.TRNS, keycodes.B},
+		},
+	}
+}
+
+func TestLayerMomentary(t *testing.T) {
+	r := newTestRig(layerTestLayers(),
+		[]sim.Op{sim.Press(10, posFn0)},
+		sim.Tap(20, 10, posA),
+		[]sim.Op{sim.Release(40, posFn0)},
+		sim.Tap(50, 10, posA),
+	)
+	r.kbd.WithActions([]keyboard.Action{keyboard.ActionLayerMomentary(1)})
+	r.run(15)
+	if !r.kbd.LayerState().IsOn(1) {
+		t.Fatalf("layer 1 is not on while FN0 is held: %08X", r.kbd.LayerState())
+	}
+	r.run(100)
+	if r.kbd.LayerState().IsOn(1) {
+		t.Fatalf("layer 1 is still on after FN0 was released: %08X", r.kbd.LayerState())
+	}
+	want := []keycodes.Keycode{keycodes.B, keycodes.NO, keycodes.A, keycodes.NO}
+	if got := r.keys(); !equalKeys(got, want) {
+		t.Fatalf("got keys %v, want %v\n%s", got, want, r.host.String())
+	}
+}
+
+func TestLayerToggle(t *testing.T) {
+	r := newTestRig(layerTestLayers(),
+		sim.Tap(10, 10, posFn1),
+		sim.Tap(30, 10, posA),
+		sim.Tap(50, 10, posFn1),
+		sim.Tap(70, 10, posA),
+	)
+	r.kbd.WithActions([]keyboard.Action{{}, keyboard.ActionLayerToggle(1)})
+	r.run(25)
+	if !r.kbd.LayerState().IsOn(1) {
+		t.Fatalf("layer 1 is not on after FN1 was tapped: %08X", r.kbd.LayerState())
+	}
+	r.run(100)
+	if r.kbd.LayerState().IsOn(1) {
+		t.Fatalf("layer 1 is still on after FN1 was tapped again: %08X", r.kbd.LayerState())
+	}
+	want := []keycodes.Keycode{keycodes.B, keycodes.NO, keycodes.A, keycodes.NO}
+	if got := r.keys(); !equalKeys(got, want) {
+		t.Fatalf("got keys %v, want %v\n%s", got, want, r.host.String())
+	}
+}
+
+// TestLayerModifier checks that modifiers, which follow FN31 in the keycode
+// space, are not mistaken for Fn keys
+func TestLayerModifier(t *testing.T) {
+	r := newTestRig(layerTestLayers(),
+		[]sim.Op{sim.Press(10, posShift)},
+		sim.Tap(20, 10, posA),
+		[]sim.Op{sim.Release(40, posShift)},
+	)
+	r.kbd.WithActions([]keyboard.Action{keyboard.ActionLayerMomentary(1)})
+	r.run(100)
+	var got []keyboard.Report
+	for _, e := range r.host.Entries() {
+		got = append(got, e.Report)
+	}
+	shift := byte(keyboard.KbdModShiftLeft)
+	want := []keyboard.Report{
+		{shift, 0, 0, 0, 0, 0, 0, 0},
+		{shift, 0, byte(keycodes.A), 0, 0, 0, 0, 0},
+		{shift, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d reports, want %d\n%s", len(got), len(want), r.host.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("report %d: got %s, want %s", i, got[i].String(), want[i].String())
+		}
+	}
+	for code := keycodes.Keycode(keycodes.LCTRL); code <= keycodes.RGUI; code++ {
+		if code.IsFn() {
+			t.Errorf("%s is an Fn keycode", code.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package keyboard
+
+import (
+	"fmt"
+)
+
+// LED is the state of the keyboard LEDs set by the host in the output report,
+// using the bit layout of the boot protocol
+type LED uint8
+
+const (
+	LEDNumLock LED = 1 << iota
+	LEDCapsLock
+	LEDScrollLock
+	LEDCompose
+	LEDKana
+)
+
+//go:inline
+func (leds LED) IsOn(led LED) bool {
+	return leds&led > 0
+}
+
+// LEDHost is implemented by hosts that can receive the LED output report.  The
+// keyboard polls it on every Task, so LEDs should return quickly.
+type LEDHost interface {
+	Host
+	LEDs() LED
+}
+
+// WithLEDHook sets a function that is called whenever the LED state changes,
+// e.g. to drive indicator pins
+func (kbd *Keyboard) WithLEDHook(hook func(leds LED)) *Keyboard {
+	kbd.ledHook = hook
+	return kbd
+}
+
+// LEDs returns the last LED state received from the host
+func (kbd *Keyboard) LEDs() LED {
+	return kbd.leds
+}
+
+// SetLEDs updates the LED state; it can be called by hosts that are notified
+// of the output report instead of implementing LEDHost.
+func (kbd *Keyboard) SetLEDs(leds LED) {
+	if leds == kbd.leds {
+		return
+	}
+	kbd.leds = leds
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "leds => %02X\r\n", uint8(leds))
+	}
+	if kbd.ledHook != nil {
+		kbd.ledHook(leds)
+	}
+}
+
+func (kbd *Keyboard) ledsTask() {
+	if kbd.ledHost != nil {
+		kbd.SetLEDs(kbd.ledHost.LEDs())
+	}
+}
@@ -0,0 +1,38 @@
+package keyboard_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+)
+
+func TestLEDHook(t *testing.T) {
+	r := newTestRig(layerTestLayers())
+	var calls []keyboard.LED
+	r.kbd.WithLEDHook(func(leds keyboard.LED) {
+		calls = append(calls, leds)
+	})
+	steps := []keyboard.LED{
+		keyboard.LEDCapsLock,
+		keyboard.LEDCapsLock,
+		keyboard.LEDCapsLock | keyboard.LEDNumLock,
+		0,
+	}
+	for i, leds := range steps {
+		r.host.SetLEDs(leds)
+		// the hook only fires once however many times the state is polled
+		r.run(uint32(i+1) * 10)
+	}
+	want := []keyboard.LED{
+		keyboard.LEDCapsLock,
+		keyboard.LEDCapsLock | keyboard.LEDNumLock,
+		0,
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Fatalf("hook called with %v, want %v", calls, want)
+	}
+	if leds := r.kbd.LEDs(); leds != 0 {
+		t.Fatalf("LEDs() = %02X, want 0", leds)
+	}
+}
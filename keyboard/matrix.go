@@ -5,25 +5,48 @@ import (
 	"io"
 	"math/bits"
 	"strings"
-	"time"
 
 	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
 	"github.com/bgould/tinygo-model-m/timer"
 )
 
-type Keymap [8][16]keycodes.Keycode
+// Keymap holds the keycode for each position in the matrix, indexed by row and
+// then by column
+type Keymap [][]keycodes.Keycode
 
-func (keymap *Keymap) KeyAt(position Pos) keycodes.Keycode {
+func NewKeymap(rows, cols uint8) Keymap {
+	keymap := make(Keymap, rows)
+	for i := range keymap {
+		keymap[i] = make([]keycodes.Keycode, cols)
+	}
+	return keymap
+}
+
+func (keymap Keymap) KeyAt(position Pos) keycodes.Keycode {
+	if int(position.Row) >= len(keymap) || int(position.Col) >= len(keymap[position.Row]) {
+		return keycodes.NO
+	}
 	return keymap[position.Row][position.Col]
 }
 
+// Find returns the position of the first occurrence of key in the keymap
+func (keymap Keymap) Find(key keycodes.Keycode) (Pos, bool) {
+	for i, row := range keymap {
+		for j, k := range row {
+			if k == key {
+				return Pos{uint8(i), uint8(j)}, true
+			}
+		}
+	}
+	return Pos{}, false
+}
+
 const (
 	DebounceMS = 4
-	MatrixRows = 8
-	MatrixCols = 16
+	MaxCols    = 32
 )
 
-type Row uint16
+type Row uint32
 
 //go:inline
 func (r Row) IsOn(col uint8) bool {
@@ -40,29 +63,51 @@ func (fn RowReaderFunc) ReadRow(rowIndex uint8) Row {
 	return fn(rowIndex)
 }
 
-func NewMatrix(io RowReader) *Matrix {
-	matrix := &Matrix{io: io}
+// NewMatrix creates a matrix of the specified size (up to MaxCols columns)
+// that reads its rows with io and filters them with the debouncer; if
+// debouncer is nil, SymDeferGlobal is used with DebounceMS.
+func NewMatrix(rows, cols uint8, io RowReader, clock timer.Clock, debouncer Debouncer) *Matrix {
+	if cols > MaxCols {
+		cols = MaxCols
+	}
+	if debouncer == nil {
+		debouncer = NewSymDeferGlobal(DebounceMS)
+	}
+	debouncer.Init(rows, cols)
+	matrix := &Matrix{
+		io:        io,
+		clock:     clock,
+		debouncer: debouncer,
+		cols:      cols,
+		rows:      make([]Row, rows),
+		raw:       make([]Row, rows),
+	}
 	return matrix
 }
 
 type Matrix struct {
-	io         RowReader
-	rows       [MatrixRows]Row
-	debouncing [MatrixRows]Row
-	debounce   uint8
+	io        RowReader
+	clock     timer.Clock
+	debouncer Debouncer
+	cols      uint8
+	rows      []Row
+	raw       []Row
 }
 
 func (m *Matrix) Rows() uint8 {
-	return MatrixRows
+	return uint8(len(m.rows))
 }
 
 func (m *Matrix) Cols() uint8 {
-	return MatrixCols
+	return m.cols
 }
 
 //go:inline
 func (m *Matrix) GetRow(row uint8) Row {
-	return m.rows[row&7]
+	if int(row) >= len(m.rows) {
+		return 0
+	}
+	return m.rows[row]
 }
 
 func (m *Matrix) IsOn(row uint8, col uint8) bool {
@@ -72,19 +117,13 @@ func (m *Matrix) IsOn(row uint8, col uint8) bool {
 func (m *Matrix) HasGhostInRow(row uint8) bool {
 	r := m.GetRow(row)
 	// if there are less than 2 keys down in the row, there is no ghost
-	n := 0
-	for i := uint8(0); i < MatrixCols; i++ {
-		if r.IsOn(i) {
-			n++
-		}
-	}
-	if n < 2 {
+	if bits.OnesCount32(uint32(r)) < 2 {
 		return false
 	}
 	//if (r - 1&r) == 0 { // TODO: copied from TMK; evaluate to see if this works
 	//	return false
 	//}
-	for i := uint8(0); i < MatrixRows; i++ {
+	for i, rows := uint8(0), m.Rows(); i < rows; i++ {
 		if i != row && m.GetRow(i)&r > 0 {
 			return true
 		}
@@ -94,43 +133,26 @@ func (m *Matrix) HasGhostInRow(row uint8) bool {
 
 func (m *Matrix) Scan() (changed bool) {
 	// loop over rows and probe the columns for each
-	for i := uint8(0); i < MatrixRows; i++ {
-		// read row, and if changed check to mark it for debouncing
-		if row := m.io.ReadRow(i); m.debouncing[i] != row {
-			//changed = true
-			//m.rows[i] = row
-			m.debouncing[i] = row
-			m.debounce = DebounceMS
-		}
+	for i := range m.raw {
+		m.raw[i] = m.io.ReadRow(uint8(i)) & (1<<m.cols - 1)
 	}
-	// if matrix is debouncing, decrement the countdown
-	if m.debounce > 0 {
-		// decrement the debounce counter
-		m.debounce -= 1
-		// if still debouncing, wait an interval before returning
-		if m.debounce > 0 {
-			timer.Wait(1 * time.Millisecond)
-			return
-		}
-		// if debouncing is complete, update the matrix and mark as changed
-		for i, row := range m.debouncing {
-			m.rows[i] = row
-		}
-		changed = true
-	}
-	return
+	return m.debouncer.Debounce(m.raw, m.rows, timer.Millis(m.clock))
 }
 
+const colDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUV"
+
 func (m *Matrix) Print(w io.Writer) {
-	fmt.Fprintf(w, "  0123456789ABCDEF\r\n")
-	fmt.Fprintf(w, " +----------------+\r\n")
+	cols := int(m.cols)
+	line := " +" + strings.Repeat("-", cols) + "+\r\n"
+	fmt.Fprintf(w, "  %s\r\n", colDigits[:cols])
+	fmt.Fprint(w, line)
 	for i, row := range m.rows {
-		s := fmt.Sprintf("%016b", bits.Reverse16(uint16(row)))
+		s := fmt.Sprintf("%0*b", cols, bits.Reverse32(uint32(row))>>(32-cols))
 		g := ""
 		if m.HasGhostInRow(uint8(i)) {
 			g = " <ghost"
 		}
-		fmt.Fprintf(w, "%X|%s|%s\r\n", byte(i), strings.ReplaceAll(s, "0", "."), g)
+		fmt.Fprintf(w, "%c|%s|%s\r\n", colDigits[i%len(colDigits)], strings.ReplaceAll(s, "0", "."), g)
 	}
-	fmt.Fprintf(w, " +----------------+\r\n")
+	fmt.Fprint(w, line)
 }
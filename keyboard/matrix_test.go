@@ -0,0 +1,168 @@
+package keyboard_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+// scanned presses the keys at pos on a simulated matrix and returns a matrix
+// that has scanned it
+func scanned(rows, cols uint8, ghosting bool, pos ...keyboard.Pos) *keyboard.Matrix {
+	clock := timer.NewFake()
+	m := sim.NewMatrix(rows, clock)
+	m.Ghosting = ghosting
+	for _, p := range pos {
+		m.Script(sim.Press(0, p))
+	}
+	matrix := keyboard.NewMatrix(rows, cols, m, clock, keyboard.NewSymDeferGlobal(0))
+	matrix.Scan()
+	return matrix
+}
+
+func TestMatrixScan(t *testing.T) {
+	p := func(row, col uint8) keyboard.Pos { return keyboard.Pos{Row: row, Col: col} }
+	tests := []struct {
+		name     string
+		rows     uint8
+		cols     uint8
+		ghosting bool
+		pressed  []keyboard.Pos
+		want     map[uint8]keyboard.Row
+	}{
+		{
+			name: "no keys",
+			rows: 2, cols: 2,
+			want: map[uint8]keyboard.Row{},
+		},
+		{
+			name: "columns past the width of the matrix are masked",
+			rows: 2, cols: 2,
+			pressed: []keyboard.Pos{p(0, 0), p(0, 5)},
+			want:    map[uint8]keyboard.Row{0: 0b01},
+		},
+		{
+			name: "rectangle without ghosting",
+			rows: 2, cols: 2,
+			pressed: []keyboard.Pos{p(0, 0), p(0, 1), p(1, 0)},
+			want:    map[uint8]keyboard.Row{0: 0b11, 1: 0b01},
+		},
+		{
+			name: "rectangle with ghosting",
+			rows: 2, cols: 2, ghosting: true,
+			pressed: []keyboard.Pos{p(0, 0), p(0, 1), p(1, 0)},
+			want:    map[uint8]keyboard.Row{0: 0b11, 1: 0b11},
+		},
+		{
+			name: "ghosting through a chain of rows",
+			rows: 3, cols: 3, ghosting: true,
+			pressed: []keyboard.Pos{p(0, 0), p(1, 0), p(1, 1), p(2, 1), p(2, 2)},
+			want:    map[uint8]keyboard.Row{0: 0b111, 1: 0b111, 2: 0b111},
+		},
+		{
+			name: "ghosting with more than 64 rows",
+			rows: 70, cols: 2, ghosting: true,
+			pressed: []keyboard.Pos{p(1, 0), p(66, 0), p(66, 1)},
+			want:    map[uint8]keyboard.Row{1: 0b11, 66: 0b11},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matrix := scanned(tt.rows, tt.cols, tt.ghosting, tt.pressed...)
+			for i := uint8(0); i < tt.rows; i++ {
+				if got := matrix.GetRow(i); got != tt.want[i] {
+					t.Errorf("row %d: got %b, want %b", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMatrixHasGhostInRow(t *testing.T) {
+	p := func(row, col uint8) keyboard.Pos { return keyboard.Pos{Row: row, Col: col} }
+	tests := []struct {
+		name    string
+		pressed []keyboard.Pos
+		ghosts  []bool
+	}{
+		{
+			name:   "no keys",
+			ghosts: []bool{false, false, false},
+		},
+		{
+			name:    "keys in one row",
+			pressed: []keyboard.Pos{p(0, 0), p(0, 1), p(0, 2)},
+			ghosts:  []bool{false, false, false},
+		},
+		{
+			name:    "keys in one column",
+			pressed: []keyboard.Pos{p(0, 0), p(1, 0), p(2, 0)},
+			ghosts:  []bool{false, false, false},
+		},
+		{
+			name:    "keys in different rows and columns",
+			pressed: []keyboard.Pos{p(0, 0), p(1, 1), p(2, 2)},
+			ghosts:  []bool{false, false, false},
+		},
+		{
+			name:    "three corners of a rectangle",
+			pressed: []keyboard.Pos{p(0, 0), p(0, 2), p(2, 0)},
+			ghosts:  []bool{true, false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matrix := scanned(3, 3, true, tt.pressed...)
+			for i, want := range tt.ghosts {
+				if got := matrix.HasGhostInRow(uint8(i)); got != want {
+					t.Errorf("row %d: got ghost %t, want %t", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatrixGhostTask(t *testing.T) {
+	var (
+		posA = keyboard.Pos{Row: 0, Col: 0}
+		posB = keyboard.Pos{Row: 0, Col: 1}
+		posC = keyboard.Pos{Row: 1, Col: 0}
+	)
+	tests := []struct {
+		name string
+		ops  [][]sim.Op
+		want []string
+	}{
+		{
+			name: "ghosted row is skipped",
+			ops: [][]sim.Op{
+				{sim.Press(10, posA), sim.Press(20, posB), sim.Press(30, posC)},
+			},
+			want: []string{"10: 00 04 00", "20: 00 04 05"},
+		},
+		{
+			name: "row is read once the ghost is gone",
+			ops: [][]sim.Op{
+				{sim.Press(10, posA), sim.Press(20, posB), sim.Press(30, posC), sim.Release(40, posB)},
+			},
+			want: []string{"10: 00 04 00", "20: 00 04 05", "40: 00 04 00", "40: 00 04 06"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRig([]keyboard.Keymap{{
+				{keycodes.A, keycodes.B},
+				{keycodes.C, keycodes.D},
+			}}, tt.ops...)
+			r.matrix.Ghosting = true
+			r.run(60)
+			if got := r.reports(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got reports:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package keyboard
+
+import (
+	"fmt"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+const (
+	MouseKeyMoveDelta  = 5
+	MouseKeyWheelDelta = 1
+	MouseKeyMoveMax    = 127
+	MouseKeyWheelMax   = 127
+)
+
+// MouseKeyConfig holds the acceleration parameters for mouse keys, which work
+// the same way as in TMK: after a key is held for Delay ms the report repeats
+// every Interval ms, and the speed ramps up linearly to MaxSpeed over
+// TimeToMax repeats.  The ACCEL0-ACCEL2 keys override the ramp with a fixed
+// speed of 1/4, 1/2 or all of the maximum while held.
+type MouseKeyConfig struct {
+	Delay          uint16
+	Interval       uint16
+	MaxSpeed       uint8
+	TimeToMax      uint8
+	WheelMaxSpeed  uint8
+	WheelTimeToMax uint8
+}
+
+func DefaultMouseKeyConfig() MouseKeyConfig {
+	return MouseKeyConfig{
+		Delay:          300,
+		Interval:       50,
+		MaxSpeed:       10,
+		TimeToMax:      20,
+		WheelMaxSpeed:  8,
+		WheelTimeToMax: 40,
+	}
+}
+
+type mouseKeys struct {
+	config MouseKeyConfig
+	report MouseReport
+	repeat uint8
+	accel  uint8
+	last   uint32
+}
+
+func (kbd *Keyboard) WithMouseKeys(config MouseKeyConfig) *Keyboard {
+	kbd.mouse.config = config
+	return kbd
+}
+
+func (mk *mouseKeys) unit(delta, max uint16, maxSpeed, timeToMax uint8) int8 {
+	var unit uint16
+	switch {
+	case mk.accel&(1<<0) > 0:
+		unit = delta * uint16(maxSpeed) / 4
+	case mk.accel&(1<<1) > 0:
+		unit = delta * uint16(maxSpeed) / 2
+	case mk.accel&(1<<2) > 0:
+		unit = delta * uint16(maxSpeed)
+	case mk.repeat == 0:
+		unit = delta
+	case mk.repeat >= timeToMax:
+		unit = delta * uint16(maxSpeed)
+	default:
+		unit = delta * uint16(maxSpeed) * uint16(mk.repeat) / uint16(timeToMax)
+		// the ramp should never be slower than the first step
+		if unit < delta {
+			unit = delta
+		}
+	}
+	if unit > max {
+		return int8(max)
+	}
+	if unit == 0 {
+		return 1
+	}
+	return int8(unit)
+}
+
+func (mk *mouseKeys) moveUnit() int8 {
+	return mk.unit(MouseKeyMoveDelta, MouseKeyMoveMax, mk.config.MaxSpeed, mk.config.TimeToMax)
+}
+
+func (mk *mouseKeys) wheelUnit() int8 {
+	return mk.unit(MouseKeyWheelDelta, MouseKeyWheelMax, mk.config.WheelMaxSpeed, mk.config.WheelTimeToMax)
+}
+
+func (mk *mouseKeys) on(key keycodes.Keycode) {
+	switch {
+	case key == keycodes.MS_UP:
+		mk.report.Y = -mk.moveUnit()
+	case key == keycodes.MS_DOWN:
+		mk.report.Y = mk.moveUnit()
+	case key == keycodes.MS_LEFT:
+		mk.report.X = -mk.moveUnit()
+	case key == keycodes.MS_RIGHT:
+		mk.report.X = mk.moveUnit()
+	case key == keycodes.MS_WH_UP:
+		mk.report.V = mk.wheelUnit()
+	case key == keycodes.MS_WH_DOWN:
+		mk.report.V = -mk.wheelUnit()
+	case key == keycodes.MS_WH_LEFT:
+		mk.report.H = -mk.wheelUnit()
+	case key == keycodes.MS_WH_RIGHT:
+		mk.report.H = mk.wheelUnit()
+	case key.IsMouseKeyButton():
+		mk.report.Buttons |= 1 << (key - keycodes.MS_BTN1)
+	case key.IsMouseKeyAccel():
+		mk.accel |= 1 << (key - keycodes.MS_ACCEL0)
+	}
+}
+
+func (mk *mouseKeys) off(key keycodes.Keycode) {
+	switch {
+	case key == keycodes.MS_UP && mk.report.Y < 0:
+		mk.report.Y = 0
+	case key == keycodes.MS_DOWN && mk.report.Y > 0:
+		mk.report.Y = 0
+	case key == keycodes.MS_LEFT && mk.report.X < 0:
+		mk.report.X = 0
+	case key == keycodes.MS_RIGHT && mk.report.X > 0:
+		mk.report.X = 0
+	case key == keycodes.MS_WH_UP && mk.report.V > 0:
+		mk.report.V = 0
+	case key == keycodes.MS_WH_DOWN && mk.report.V < 0:
+		mk.report.V = 0
+	case key == keycodes.MS_WH_LEFT && mk.report.H < 0:
+		mk.report.H = 0
+	case key == keycodes.MS_WH_RIGHT && mk.report.H > 0:
+		mk.report.H = 0
+	case key.IsMouseKeyButton():
+		mk.report.Buttons &^= 1 << (key - keycodes.MS_BTN1)
+	case key.IsMouseKeyAccel():
+		mk.accel &^= 1 << (key - keycodes.MS_ACCEL0)
+	}
+	if !mk.report.IsMoving() {
+		mk.repeat = 0
+	}
+}
+
+// task updates the report with the accelerated movement if it is time to
+// repeat it, returning true if the report should be sent
+func (mk *mouseKeys) task(now uint32) bool {
+	wait := uint32(mk.config.Delay)
+	if mk.repeat > 0 {
+		wait = uint32(mk.config.Interval)
+	}
+	if now-mk.last < wait || !mk.report.IsMoving() {
+		return false
+	}
+	if mk.repeat < 0xFF {
+		mk.repeat++
+	}
+	if mk.report.X > 0 {
+		mk.report.X = mk.moveUnit()
+	}
+	if mk.report.X < 0 {
+		mk.report.X = -mk.moveUnit()
+	}
+	if mk.report.Y > 0 {
+		mk.report.Y = mk.moveUnit()
+	}
+	if mk.report.Y < 0 {
+		mk.report.Y = -mk.moveUnit()
+	}
+	// diagonal move [1/sqrt(2) = 0.7]
+	if mk.report.X != 0 && mk.report.Y != 0 {
+		mk.report.X = diagonal(mk.report.X)
+		mk.report.Y = diagonal(mk.report.Y)
+	}
+	if mk.report.V > 0 {
+		mk.report.V = mk.wheelUnit()
+	}
+	if mk.report.V < 0 {
+		mk.report.V = -mk.wheelUnit()
+	}
+	if mk.report.H > 0 {
+		mk.report.H = mk.wheelUnit()
+	}
+	if mk.report.H < 0 {
+		mk.report.H = -mk.wheelUnit()
+	}
+	return true
+}
+
+// diagonal scales one axis of a diagonal move, keeping at least a unit of
+// movement so that the direction is not lost on the next repeat
+func diagonal(v int8) int8 {
+	switch d := int8(int16(v) * 7 / 10); {
+	case d == 0 && v > 0:
+		return 1
+	case d == 0 && v < 0:
+		return -1
+	default:
+		return d
+	}
+}
+
+func (kbd *Keyboard) processMouseKey(key keycodes.Keycode, ev Event) {
+	if ev.Made {
+		kbd.mouse.on(key)
+	} else {
+		kbd.mouse.off(key)
+	}
+	kbd.sendMouse(ev.Time)
+}
+
+func (kbd *Keyboard) mouseKeysTask(now uint32) {
+	if kbd.mouse.task(now) {
+		kbd.sendMouse(now)
+	}
+}
+
+func (kbd *Keyboard) sendMouse(now uint32) {
+	kbd.mouse.last = now
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "mouse => %s\r\n", kbd.mouse.report.String())
+	}
+	kbd.host.SendMouse(&kbd.mouse.report)
+}
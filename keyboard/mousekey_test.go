@@ -0,0 +1,127 @@
+package keyboard_test
+
+import (
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+)
+
+// mouseTestLayers has the mouse keys used by the tests in a single row, so
+// that they cannot ghost
+func mouseTestLayers() []keyboard.Keymap {
+	return []keyboard.Keymap{{
+		{keycodes.MS_RIGHT, keycodes.MS_DOWN, keycodes.MS_WH_UP, keycodes.MS_ACCEL0},
+	}}
+}
+
+var (
+	posMsRight  = keyboard.Pos{Row: 0, Col: 0}
+	posMsDown   = keyboard.Pos{Row: 0, Col: 1}
+	posMsWhUp   = keyboard.Pos{Row: 0, Col: 2}
+	posMsAccel0 = keyboard.Pos{Row: 0, Col: 3}
+)
+
+// mouse returns the recorded mouse reports
+func (r *testRig) mouse() []keyboard.MouseReport {
+	var reports []keyboard.MouseReport
+	for _, e := range r.host.Entries() {
+		if e.Kind == sim.MouseEntry {
+			reports = append(reports, e.Mouse)
+		}
+	}
+	return reports
+}
+
+func equalInt8s(got, want []int8) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMouseKeyAcceleration(t *testing.T) {
+	// the report repeats after 300ms and then every 50ms, ramping up by
+	// 5*10/20 per repeat but never slower than the first step of 5
+	r := newTestRig(mouseTestLayers(), sim.Tap(10, 580, posMsRight))
+	r.run(1000)
+	var x []int8
+	for _, m := range r.mouse() {
+		x = append(x, m.X)
+	}
+	want := []int8{5, 5, 5, 7, 10, 12, 15, 0}
+	if !equalInt8s(x, want) {
+		t.Errorf("x = %v, want %v", x, want)
+	}
+}
+
+func TestMouseKeyWheel(t *testing.T) {
+	// with a delta of 1 the ramp is 8/40 per repeat, so it stays at 1 until
+	// the 10th repeat
+	r := newTestRig(mouseTestLayers(), sim.Tap(10, 780, posMsWhUp))
+	r.run(1000)
+	var v []int8
+	for _, m := range r.mouse() {
+		v = append(v, m.V)
+	}
+	want := []int8{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 2, 0}
+	if !equalInt8s(v, want) {
+		t.Errorf("v = %v, want %v", v, want)
+	}
+}
+
+func TestMouseKeyDiagonal(t *testing.T) {
+	type xy struct{ x, y int8 }
+	tests := []struct {
+		name   string
+		config keyboard.MouseKeyConfig
+		ops    [][]sim.Op
+		want   []xy
+	}{
+		{
+			name:   "scaled",
+			config: keyboard.DefaultMouseKeyConfig(),
+			ops:    [][]sim.Op{sim.Tap(10, 400, posMsRight), sim.Tap(10, 400, posMsDown)},
+			want:   []xy{{5, 0}, {5, 5}, {3, 3}, {3, 3}, {0, 3}, {0, 0}},
+		},
+		{
+			// ACCEL0 with a max speed of 1 moves by 5/4 = 1, which must not
+			// be scaled down to 0
+			name: "clamped",
+			config: keyboard.MouseKeyConfig{
+				Delay: 300, Interval: 50, MaxSpeed: 1, TimeToMax: 20,
+			},
+			ops: [][]sim.Op{
+				sim.Tap(5, 500, posMsAccel0),
+				sim.Tap(10, 400, posMsRight),
+				sim.Tap(10, 400, posMsDown),
+			},
+			want: []xy{{0, 0}, {1, 0}, {1, 1}, {1, 1}, {1, 1}, {0, 1}, {0, 0}, {0, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRig(mouseTestLayers(), tt.ops...)
+			r.kbd.WithMouseKeys(tt.config)
+			r.run(1000)
+			var got []xy
+			for _, m := range r.mouse() {
+				got = append(got, xy{m.X, m.Y})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
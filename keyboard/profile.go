@@ -0,0 +1,40 @@
+package keyboard
+
+import (
+	"fmt"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+// ProfileHost is implemented by hosts that can pair with several devices and
+// switch between them, such as a Bluetooth module.  Profiles are numbered
+// from 0 for the PROFILE1 keycode.
+type ProfileHost interface {
+	Host
+	SelectProfile(profile uint8)
+	ClearProfiles()
+}
+
+// processProfile switches profiles on press; the keys that are down are
+// released first so that none are left stuck on the device being switched
+// away from
+func (kbd *Keyboard) processProfile(key keycodes.Keycode, ev Event) {
+	if !ev.Made || kbd.profileHost == nil {
+		return
+	}
+	kbd.report.Keyboard(0)
+	kbd.nkro = NKROReport{}
+	kbd.sendReport()
+	if key == keycodes.PROFILE_CLEAR {
+		if kbd.debug {
+			fmt.Fprintf(kbd.console, "profiles => clear\r\n")
+		}
+		kbd.profileHost.ClearProfiles()
+		return
+	}
+	profile := uint8(key - keycodes.PROFILE1)
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "profile => %d\r\n", profile)
+	}
+	kbd.profileHost.SelectProfile(profile)
+}
@@ -0,0 +1,87 @@
+package keyboard_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+// profileRecorder is a Recorder that also logs the profile changes among the
+// reports it receives
+type profileRecorder struct {
+	*sim.Recorder
+	log []string
+}
+
+func (r *profileRecorder) Send(report *keyboard.Report) {
+	r.log = append(r.log, "kbd "+report.String())
+}
+
+func (r *profileRecorder) SendNKRO(report *keyboard.NKROReport) {
+	r.log = append(r.log, fmt.Sprintf("nkro A: %t", report.IsOn(keycodes.A)))
+}
+
+func (r *profileRecorder) SelectProfile(profile uint8) {
+	r.log = append(r.log, fmt.Sprintf("select %d", profile))
+}
+
+func (r *profileRecorder) ClearProfiles() {
+	r.log = append(r.log, "clear")
+}
+
+func TestProfileReleasesKeys(t *testing.T) {
+	layers := []keyboard.Keymap{{
+		{keycodes.A, keycodes.PROFILE2, keycodes.PROFILE_CLEAR},
+	}}
+	for _, tt := range []struct {
+		name string
+		nkro bool
+		want []string
+	}{
+		{
+			name: "boot",
+			want: []string{
+				"kbd [ 00 00 04 00 00 00 00 00 ]",
+				"kbd [ 00 00 00 00 00 00 00 00 ]",
+				"select 1",
+				"kbd [ 00 00 00 00 00 00 00 00 ]",
+				"clear",
+				// A is released after it was already cleared by the switch
+				"kbd [ 00 00 00 00 00 00 00 00 ]",
+			},
+		},
+		{
+			name: "nkro",
+			nkro: true,
+			want: []string{
+				"nkro A: true",
+				"nkro A: false",
+				"select 1",
+				"nkro A: false",
+				"clear",
+				"nkro A: false",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := timer.NewFake()
+			host := &profileRecorder{Recorder: sim.NewRecorder(clock)}
+			m := sim.NewMatrix(1, clock,
+				sim.Tap(10, 100, keyboard.Pos{Row: 0, Col: 0}),
+				sim.Tap(30, 20, keyboard.Pos{Row: 0, Col: 1}),
+				sim.Tap(70, 20, keyboard.Pos{Row: 0, Col: 2}),
+			)
+			matrix := keyboard.NewMatrix(1, 3, m, clock, keyboard.NewSymDeferGlobal(0))
+			kbd := keyboard.New(&bytes.Buffer{}, host, matrix, layers).WithClock(clock).WithNKRO(tt.nkro)
+			sim.Run(clock, 200, kbd.Task)
+			if fmt.Sprint(host.log) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %q, want %q", host.log, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package keyboard
+
+import (
+	"errors"
+)
+
+const EventQueueSize = 32
+
+var ErrQueueFull = errors.New("event queue full")
+
+// EventQueue is a bounded FIFO of events between the matrix scan and the
+// processing of actions.  Events stay in the queue until they are popped, so
+// actions such as tap-hold keys can look ahead at the events that follow them
+// before deciding what to do.
+type EventQueue struct {
+	buf     []Event
+	head    int
+	count   int
+	dropped uint32
+}
+
+// NewEventQueue returns a queue that holds up to size events; a size of less
+// than one is raised to one, since the keyboard cannot make progress without
+// room for at least one event.
+func NewEventQueue(size int) *EventQueue {
+	if size < 1 {
+		size = 1
+	}
+	return &EventQueue{buf: make([]Event, size)}
+}
+
+// Push adds an event at the end of the queue, or returns ErrQueueFull if there
+// is no room left for it.
+func (q *EventQueue) Push(ev Event) error {
+	if q.count == len(q.buf) {
+		q.dropped++
+		return ErrQueueFull
+	}
+	q.buf[(q.head+q.count)%len(q.buf)] = ev
+	q.count++
+	return nil
+}
+
+func (q *EventQueue) Pop() (ev Event, ok bool) {
+	if q.count == 0 {
+		return
+	}
+	ev = q.buf[q.head]
+	q.head = (q.head + 1) % len(q.buf)
+	q.count--
+	return ev, true
+}
+
+// Peek returns the i-th event from the front of the queue without removing it
+func (q *EventQueue) Peek(i int) (ev Event, ok bool) {
+	if i < 0 || i >= q.count {
+		return
+	}
+	return q.buf[(q.head+i)%len(q.buf)], true
+}
+
+func (q *EventQueue) Len() int {
+	return q.count
+}
+
+func (q *EventQueue) Cap() int {
+	return len(q.buf)
+}
+
+// Full returns true if there is no room for another event
+func (q *EventQueue) Full() bool {
+	return q.count == len(q.buf)
+}
+
+// Dropped returns the number of events that were lost because the queue was
+// full, either because Push failed or because the keyboard could not queue a
+// change before the key changed back
+func (q *EventQueue) Dropped() uint32 {
+	return q.dropped
+}
+
+func (q *EventQueue) Clear() {
+	q.head = 0
+	q.count = 0
+}
@@ -0,0 +1,147 @@
+package keyboard_test
+
+import (
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+)
+
+func TestEventQueue(t *testing.T) {
+	q := keyboard.NewEventQueue(3)
+	ev := func(col uint8) keyboard.Event {
+		return keyboard.Event{Pos: keyboard.Pos{Col: col}, Made: true, Time: uint32(col)}
+	}
+	// fill and drain a few times so that the ring wraps around
+	for round := uint8(0); round < 3; round++ {
+		for i := uint8(0); i < 3; i++ {
+			if err := q.Push(ev(round*3 + i)); err != nil {
+				t.Fatalf("round %d: push %d: %v", round, i, err)
+			}
+		}
+		if !q.Full() || q.Len() != 3 {
+			t.Fatalf("round %d: full: %t, len: %d", round, q.Full(), q.Len())
+		}
+		if e, ok := q.Peek(2); !ok || e != ev(round*3+2) {
+			t.Fatalf("round %d: peek 2: %v %t", round, e, ok)
+		}
+		if _, ok := q.Peek(3); ok {
+			t.Fatalf("round %d: peek past the end", round)
+		}
+		for i := uint8(0); i < 3; i++ {
+			if e, ok := q.Pop(); !ok || e != ev(round*3+i) {
+				t.Fatalf("round %d: pop %d: got %v %t", round, i, e, ok)
+			}
+		}
+		if _, ok := q.Pop(); ok || q.Len() != 0 {
+			t.Fatalf("round %d: pop from empty queue", round)
+		}
+	}
+	if q.Dropped() != 0 {
+		t.Fatalf("dropped %d without overflow", q.Dropped())
+	}
+}
+
+func TestEventQueueOverflow(t *testing.T) {
+	q := keyboard.NewEventQueue(2)
+	for i := 0; i < 2; i++ {
+		if err := q.Push(keyboard.Event{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := q.Push(keyboard.Event{}); err != keyboard.ErrQueueFull {
+			t.Fatalf("push %d into a full queue: %v", i, err)
+		}
+	}
+	if q.Dropped() != 3 {
+		t.Fatalf("got %d dropped, want 3", q.Dropped())
+	}
+	q.Clear()
+	if q.Len() != 0 || q.Full() {
+		t.Fatalf("len %d after clear", q.Len())
+	}
+}
+
+// queueTestRig holds a mod-tap key undecided so that the events behind it fill
+// a queue with room for two; the keys are in a single row so that they cannot
+// ghost
+func queueTestRig(ops ...[]sim.Op) *testRig {
+	layers := []keyboard.Keymap{{
+		{keycodes.FN0, keycodes.A, keycodes.B, keycodes.C},
+	}}
+	r := newTestRig(layers, ops...)
+	r.kbd.WithEventQueue(2).WithActions([]keyboard.Action{
+		keyboard.ActionModTap(keyboard.KbdModShiftLeft, keycodes.ESC),
+	})
+	return r
+}
+
+var (
+	posQueueA = keyboard.Pos{Row: 0, Col: 1}
+	posQueueB = keyboard.Pos{Row: 0, Col: 2}
+	posQueueC = keyboard.Pos{Row: 0, Col: 3}
+)
+
+func TestEventQueueMinimumSize(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		q := keyboard.NewEventQueue(size)
+		if err := q.Push(keyboard.Event{}); err != nil {
+			t.Fatalf("size %d: push: %v", size, err)
+		}
+		if !q.Full() {
+			t.Fatalf("size %d: got room for more than one event", size)
+		}
+	}
+	// a keyboard with a zero sized queue still processes key presses
+	r := newTestRig(layerTestLayers(), sim.Tap(10, 50, posA))
+	r.kbd.WithEventQueue(0)
+	r.run(100)
+	if got, want := r.keys(), []keycodes.Keycode{keycodes.A, 0}; !equalKeys(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestEventQueueDeferred(t *testing.T) {
+	r := queueTestRig(
+		[]sim.Op{sim.Press(10, posFn0)},
+		[]sim.Op{sim.Press(12, posQueueA), sim.Press(14, posQueueB), sim.Press(16, posQueueC)},
+	)
+	r.run(100)
+	if n := r.kbd.Events().Len(); n != 2 {
+		t.Fatalf("got %d queued events while the tap-hold key is undecided, want 2", n)
+	}
+	r.run(300)
+	if d := r.kbd.Events().Dropped(); d != 0 {
+		t.Fatalf("a deferred event was counted as dropped %d times", d)
+	}
+	var last keyboard.Report
+	for _, e := range r.host.Entries() {
+		last = e.Report
+	}
+	want := keyboard.Report{byte(keyboard.KbdModShiftLeft), 0,
+		byte(keycodes.A), byte(keycodes.B), byte(keycodes.C), 0, 0, 0}
+	if last != want {
+		t.Fatalf("got %s, want %s\n%s", last.String(), want.String(), r.host.String())
+	}
+}
+
+func TestEventQueueLost(t *testing.T) {
+	r := queueTestRig(
+		[]sim.Op{sim.Press(10, posFn0)},
+		[]sim.Op{sim.Press(12, posQueueA), sim.Press(14, posQueueB)},
+		sim.Tap(16, 4, posQueueC),
+	)
+	r.run(300)
+	if d := r.kbd.Events().Dropped(); d != 2 {
+		t.Fatalf("got %d dropped, want 2 for the press and release of C", d)
+	}
+	for _, e := range r.host.Entries() {
+		for _, key := range e.Report[2:] {
+			if key == byte(keycodes.C) {
+				t.Fatalf("lost key C was sent\n%s", r.host.String())
+			}
+		}
+	}
+}
@@ -37,6 +37,9 @@ func (r *Report) Make(key keycodes.Keycode) {
 		r[0] |= 1 << (key & 0x07)
 		return
 	}
+	if !key.IsKey() {
+		return
+	}
 	firstZero := 0
 	for i := 2; i < 8; i++ {
 		switch keycodes.Keycode(r[i]) {
@@ -50,6 +53,12 @@ func (r *Report) Make(key keycodes.Keycode) {
 	}
 	if firstZero > 0 {
 		r[firstZero] = byte(key)
+		return
+	}
+	// too many keys are down; as per the HID spec, report the error rollover
+	// in every slot rather than an arbitrary subset of the keys
+	for i := 2; i < 8; i++ {
+		r[i] = byte(keycodes.ROLL_OVER)
 	}
 }
 
@@ -65,6 +74,22 @@ func (r *Report) Break(key keycodes.Keycode) {
 	}
 }
 
+// IsRollOver returns true if the report signals that too many keys are down
+func (r *Report) IsRollOver() bool {
+	return keycodes.Keycode(r[2]) == keycodes.ROLL_OVER
+}
+
+// FromNKRO replaces the contents of the report with the keys that are down in
+// the NKRO report, or ROLL_OVER if there are more than fit.
+func (r *Report) FromNKRO(n *NKROReport) {
+	r.Keyboard(KeyboardModifier(n[nkroModByte]))
+	for key := keycodes.A; key <= keycodes.EXSEL; key++ {
+		if n.IsOn(key) {
+			r.Make(key)
+		}
+	}
+}
+
 func (r *Report) String() string {
 	return fmt.Sprintf(
 		"[ %02X %02X %02X %02X %02X %02X %02X %02X ]",
@@ -85,55 +110,154 @@ func (r *Report) Keyboard(mod KeyboardModifier, keys ...byte) *Report {
 	return r
 }
 
-/*
-type MouseButton byte
+// NKROReportSize is the number of bytes in a bitmap covering usages 0x00-0xE7
+const NKROReportSize = 29
+
+const nkroModByte = keycodes.LCTRL / 8
+
+// NKROReport is an n-key rollover report: a bitmap of the usages on the
+// Keyboard page that are down, with usage n at bit n%8 of byte n/8.  The
+// modifiers end up in the last byte in the same layout as the boot report.
+type NKROReport [NKROReportSize]byte
+
+func (r *NKROReport) Make(key keycodes.Keycode) {
+	if key.IsKey() || key.IsModifier() {
+		r[key/8] |= 1 << (key % 8)
+	}
+}
+
+func (r *NKROReport) Break(key keycodes.Keycode) {
+	if key.IsKey() || key.IsModifier() {
+		r[key/8] &^= 1 << (key % 8)
+	}
+}
+
+func (r *NKROReport) IsOn(key keycodes.Keycode) bool {
+	if int(key/8) >= len(r) {
+		return false
+	}
+	return r[key/8]&(1<<(key%8)) > 0
+}
+
+func (r *NKROReport) String() string {
+	return fmt.Sprintf("[ % X ]", r[:])
+}
+
+type MouseButton uint8
 
 const (
-	MouseBtnLeft   MouseButton = 0x01
-	MouseBtnRight  MouseButton = 0x02
-	MouseBtnMiddle MouseButton = 0x04
+	MouseBtn1 MouseButton = 1 << iota
+	MouseBtn2
+	MouseBtn3
+	MouseBtn4
+	MouseBtn5
+
+	MouseBtnLeft   = MouseBtn1
+	MouseBtnRight  = MouseBtn2
+	MouseBtnMiddle = MouseBtn3
 )
 
-func (r *Report) Mouse(buttons MouseButton, x int8, y int8) *Report {
-	r[0] = 0x0
-	r[1] = 0x3
-	r[2] = byte(buttons)
-	r[3] = byte(x)
-	r[4] = byte(y)
-	r[5] = 0x0
-	r[6] = 0x0
-	r[7] = 0x0
-	return r
+// MouseReport holds the button state and relative movement for the cursor (X
+// and Y) and the vertical and horizontal scroll wheels (V and H)
+type MouseReport struct {
+	Buttons MouseButton
+	X       int8
+	Y       int8
+	V       int8
+	H       int8
+}
+
+func (r *MouseReport) IsMoving() bool {
+	return r.X != 0 || r.Y != 0 || r.V != 0 || r.H != 0
+}
+
+func (r *MouseReport) String() string {
+	return fmt.Sprintf("[ btn: %02X x: %d y: %d v: %d h: %d ]",
+		uint8(r.Buttons), r.X, r.Y, r.V, r.H)
 }
 
-type ConsumerKey uint16
+// ConsumerUsage is a usage ID from the HID Consumer page (0x0C)
+type ConsumerUsage uint16
 
 const (
-	ConsKeyHome       ConsumerKey = 0x0100
-	ConsKeyKbdLayout  ConsumerKey = 0x0200
-	ConsKeySearch     ConsumerKey = 0x0400
-	ConsKeySnapshot   ConsumerKey = 0x0800
-	ConsKeyVolUp      ConsumerKey = 0x1000
-	ConsKeyVolDown    ConsumerKey = 0x2000
-	ConsKeyPlayPause  ConsumerKey = 0x4000
-	ConsKeyFastFwd    ConsumerKey = 0x8000
-	ConsKeyRewind     ConsumerKey = 0x0001
-	ConsKeyNextTrack  ConsumerKey = 0x0002
-	ConsKeyPrevTrack  ConsumerKey = 0x0004
-	ConsKeyRandomPlay ConsumerKey = 0x0008
-	ConsKeyStop       ConsumerKey = 0x0010
+	ConsumerNone         ConsumerUsage = 0x0000
+	ConsumerNextTrack    ConsumerUsage = 0x00B5
+	ConsumerPrevTrack    ConsumerUsage = 0x00B6
+	ConsumerFastForward  ConsumerUsage = 0x00B3
+	ConsumerRewind       ConsumerUsage = 0x00B4
+	ConsumerStop         ConsumerUsage = 0x00B7
+	ConsumerEject        ConsumerUsage = 0x00B8
+	ConsumerPlayPause    ConsumerUsage = 0x00CD
+	ConsumerMute         ConsumerUsage = 0x00E2
+	ConsumerVolumeUp     ConsumerUsage = 0x00E9
+	ConsumerVolumeDown   ConsumerUsage = 0x00EA
+	ConsumerMediaSelect  ConsumerUsage = 0x0183
+	ConsumerMail         ConsumerUsage = 0x018A
+	ConsumerCalculator   ConsumerUsage = 0x0192
+	ConsumerMyComputer   ConsumerUsage = 0x0194
+	ConsumerWWWSearch    ConsumerUsage = 0x0221
+	ConsumerWWWHome      ConsumerUsage = 0x0223
+	ConsumerWWWBack      ConsumerUsage = 0x0224
+	ConsumerWWWForward   ConsumerUsage = 0x0225
+	ConsumerWWWStop      ConsumerUsage = 0x0226
+	ConsumerWWWRefresh   ConsumerUsage = 0x0227
+	ConsumerWWWFavorites ConsumerUsage = 0x022A
 )
 
-func (r *Report) Consumer(key ConsumerKey) *Report {
-	r[0] = 0x0
-	r[1] = 0x2
-	r[2] = byte(key >> 8)
-	r[3] = byte(key & 0xFF)
-	r[4] = 0x0
-	r[5] = 0x0
-	r[6] = 0x0
-	r[7] = 0x0
-	return r
+// SystemUsage is a usage ID from the Generic Desktop page (0x01) for the
+// System Control collection
+type SystemUsage uint16
+
+const (
+	SystemNone   SystemUsage = 0x00
+	SystemPower  SystemUsage = 0x81
+	SystemSleep  SystemUsage = 0x82
+	SystemWakeUp SystemUsage = 0x83
+)
+
+var consumerUsages = [...]ConsumerUsage{
+	keycodes.AUDIO_MUTE - keycodes.AUDIO_MUTE:         ConsumerMute,
+	keycodes.AUDIO_VOL_UP - keycodes.AUDIO_MUTE:       ConsumerVolumeUp,
+	keycodes.AUDIO_VOL_DOWN - keycodes.AUDIO_MUTE:     ConsumerVolumeDown,
+	keycodes.MEDIA_NEXT_TRACK - keycodes.AUDIO_MUTE:   ConsumerNextTrack,
+	keycodes.MEDIA_PREV_TRACK - keycodes.AUDIO_MUTE:   ConsumerPrevTrack,
+	keycodes.MEDIA_FAST_FORWARD - keycodes.AUDIO_MUTE: ConsumerFastForward,
+	keycodes.MEDIA_REWIND - keycodes.AUDIO_MUTE:       ConsumerRewind,
+	keycodes.MEDIA_STOP - keycodes.AUDIO_MUTE:         ConsumerStop,
+	keycodes.MEDIA_PLAY_PAUSE - keycodes.AUDIO_MUTE:   ConsumerPlayPause,
+	keycodes.MEDIA_EJECT - keycodes.AUDIO_MUTE:        ConsumerEject,
+	keycodes.MEDIA_SELECT - keycodes.AUDIO_MUTE:       ConsumerMediaSelect,
+	keycodes.MAIL - keycodes.AUDIO_MUTE:               ConsumerMail,
+	keycodes.CALCULATOR - keycodes.AUDIO_MUTE:         ConsumerCalculator,
+	keycodes.MY_COMPUTER - keycodes.AUDIO_MUTE:        ConsumerMyComputer,
+	keycodes.WWW_SEARCH - keycodes.AUDIO_MUTE:         ConsumerWWWSearch,
+	keycodes.WWW_HOME - keycodes.AUDIO_MUTE:           ConsumerWWWHome,
+	keycodes.WWW_BACK - keycodes.AUDIO_MUTE:           ConsumerWWWBack,
+	keycodes.WWW_FORWARD - keycodes.AUDIO_MUTE:        ConsumerWWWForward,
+	keycodes.WWW_STOP - keycodes.AUDIO_MUTE:           ConsumerWWWStop,
+	keycodes.WWW_REFRESH - keycodes.AUDIO_MUTE:        ConsumerWWWRefresh,
+	keycodes.WWW_FAVORITES - keycodes.AUDIO_MUTE:      ConsumerWWWFavorites,
 }
 
-*/
+// ConsumerUsageFor translates one of the media keycodes to its usage ID on the
+// HID Consumer page, or returns ConsumerNone if the keycode is not one.
+func ConsumerUsageFor(key keycodes.Keycode) ConsumerUsage {
+	if !key.IsConsumer() {
+		return ConsumerNone
+	}
+	return consumerUsages[key-keycodes.AUDIO_MUTE]
+}
+
+// SystemUsageFor translates one of the system control keycodes to its usage
+// ID, or returns SystemNone if the keycode is not one.
+func SystemUsageFor(key keycodes.Keycode) SystemUsage {
+	switch key {
+	case keycodes.SYSTEM_POWER:
+		return SystemPower
+	case keycodes.SYSTEM_SLEEP:
+		return SystemSleep
+	case keycodes.SYSTEM_WAKE:
+		return SystemWakeUp
+	}
+	return SystemNone
+}
@@ -0,0 +1,187 @@
+package keyboard_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+)
+
+// usages returns the usage of each recorded report of the given kind
+func (r *testRig) usages(kind sim.EntryKind) []uint16 {
+	var usages []uint16
+	for _, e := range r.host.Entries() {
+		if e.Kind == kind {
+			usages = append(usages, e.Usage)
+		}
+	}
+	return usages
+}
+
+func equalUsages(got, want []uint16) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUsageKeys(t *testing.T) {
+	layers := []keyboard.Keymap{{{keycodes.MEDIA_PLAY_PAUSE, keycodes.SYSTEM_POWER}}}
+	tests := []struct {
+		name  string
+		kind  sim.EntryKind
+		pos   keyboard.Pos
+		usage uint16
+	}{
+		{"consumer", sim.ConsumerEntry, keyboard.Pos{Row: 0, Col: 0}, uint16(keyboard.ConsumerPlayPause)},
+		{"system", sim.SystemEntry, keyboard.Pos{Row: 0, Col: 1}, uint16(keyboard.SystemPower)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRig(layers, sim.Tap(10, 50, tt.pos))
+			r.run(100)
+			if got, want := r.usages(tt.kind), []uint16{tt.usage, 0}; !equalUsages(got, want) {
+				t.Errorf("usages = %04X, want %04X", got, want)
+			}
+			if keys := r.keys(); len(keys) != 0 {
+				t.Errorf("keyboard reports = %v, want none", keys)
+			}
+		})
+	}
+}
+
+func TestUsageFor(t *testing.T) {
+	consumer := map[keycodes.Keycode]keyboard.ConsumerUsage{
+		keycodes.AUDIO_MUTE:       keyboard.ConsumerMute,
+		keycodes.AUDIO_VOL_UP:     keyboard.ConsumerVolumeUp,
+		keycodes.MEDIA_PLAY_PAUSE: keyboard.ConsumerPlayPause,
+		keycodes.WWW_FAVORITES:    keyboard.ConsumerWWWFavorites,
+		keycodes.A:                keyboard.ConsumerNone,
+	}
+	for key, want := range consumer {
+		if got := keyboard.ConsumerUsageFor(key); got != want {
+			t.Errorf("ConsumerUsageFor(%v) = %04X, want %04X", key, got, want)
+		}
+	}
+	system := map[keycodes.Keycode]keyboard.SystemUsage{
+		keycodes.SYSTEM_POWER: keyboard.SystemPower,
+		keycodes.SYSTEM_SLEEP: keyboard.SystemSleep,
+		keycodes.SYSTEM_WAKE:  keyboard.SystemWakeUp,
+		keycodes.AUDIO_MUTE:   keyboard.SystemNone,
+	}
+	for key, want := range system {
+		if got := keyboard.SystemUsageFor(key); got != want {
+			t.Errorf("SystemUsageFor(%v) = %02X, want %02X", key, got, want)
+		}
+	}
+}
+
+// rollOverTestLayers has seven keys in a single row, one more than fits in a
+// boot report
+func rollOverTestLayers() []keyboard.Keymap {
+	return []keyboard.Keymap{{
+		{keycodes.A, keycodes.B, keycodes.C, keycodes.D, keycodes.E, keycodes.F, keycodes.G},
+	}}
+}
+
+func TestReportRollOver(t *testing.T) {
+	var r keyboard.Report
+	var n keyboard.NKROReport
+	for key := keycodes.A; key <= keycodes.G; key++ {
+		r.Make(key)
+		n.Make(key)
+	}
+	want := keyboard.Report{0, 0, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}
+	if r != want {
+		t.Fatalf("7 keys down: got %s, want %s", r.String(), want.String())
+	}
+	// releasing a key leaves the rollover in place until it is rebuilt from
+	// the NKRO report
+	r.Break(keycodes.C)
+	n.Break(keycodes.C)
+	if !r.IsRollOver() {
+		t.Fatalf("rollover cleared by a release: %s", r.String())
+	}
+	r.FromNKRO(&n)
+	want = keyboard.Report{0, 0, byte(keycodes.A), byte(keycodes.B), byte(keycodes.D),
+		byte(keycodes.E), byte(keycodes.F), byte(keycodes.G)}
+	if r != want {
+		t.Fatalf("after release: got %s, want %s", r.String(), want.String())
+	}
+}
+
+func TestKeyboardRollOver(t *testing.T) {
+	var ops [][]sim.Op
+	for col := uint8(0); col < 7; col++ {
+		pos := keyboard.Pos{Row: 0, Col: col}
+		hold := uint32(100)
+		if col == 2 {
+			hold = 40
+		}
+		ops = append(ops, sim.Tap(10+uint32(col)*5, hold, pos))
+	}
+	r := newTestRig(rollOverTestLayers(), ops...)
+	r.run(200)
+	var got []string
+	for _, e := range r.host.Entries() {
+		if e.Kind == sim.KeyboardEntry {
+			got = append(got, e.Report.String())
+		}
+	}
+	// C is released after the 7th key is pressed, which frees up a slot
+	want := []string{
+		"[ 00 00 04 00 00 00 00 00 ]",
+		"[ 00 00 04 05 00 00 00 00 ]",
+		"[ 00 00 04 05 06 00 00 00 ]",
+		"[ 00 00 04 05 06 07 00 00 ]",
+		"[ 00 00 04 05 06 07 08 00 ]",
+		"[ 00 00 04 05 06 07 08 09 ]",
+		"[ 00 00 01 01 01 01 01 01 ]",
+		"[ 00 00 04 05 07 08 09 0A ]",
+	}
+	if len(got) < len(want) {
+		t.Fatalf("got reports %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("report %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestKeyboardBootFallback(t *testing.T) {
+	r := newTestRig(rollOverTestLayers(),
+		sim.Tap(10, 100, keyboard.Pos{Row: 0, Col: 0}),
+		sim.Tap(50, 20, keyboard.Pos{Row: 0, Col: 1}),
+	)
+	r.kbd.WithNKRO(true)
+	r.run(30)
+	// the host switches to the boot protocol while A is held
+	r.host.Boot = true
+	r.run(200)
+	var got []string
+	for _, e := range r.host.Entries() {
+		switch e.Kind {
+		case sim.NKROEntry:
+			got = append(got, fmt.Sprintf("nkro A: %t", e.NKRO.IsOn(keycodes.A)))
+		case sim.KeyboardEntry:
+			got = append(got, "kbd "+e.Report.String())
+		}
+	}
+	want := []string{
+		"nkro A: true",
+		"kbd [ 00 00 04 05 00 00 00 00 ]",
+		"kbd [ 00 00 04 00 00 00 00 00 ]",
+		"kbd [ 00 00 00 00 00 00 00 00 ]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+// Package sim provides simulated hardware for running the keyboard package
+// off-device.
+package sim
+
+import (
+	"sort"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+// Op changes the state of the switch at Pos at time At (in milliseconds)
+type Op struct {
+	At    uint32
+	Pos   keyboard.Pos
+	Press bool
+}
+
+func Press(at uint32, pos keyboard.Pos) Op {
+	return Op{At: at, Pos: pos, Press: true}
+}
+
+func Release(at uint32, pos keyboard.Pos) Op {
+	return Op{At: at, Pos: pos}
+}
+
+// Tap presses the key at the specified time and releases it hold ms later
+func Tap(at uint32, hold uint32, pos keyboard.Pos) []Op {
+	return []Op{Press(at, pos), Release(at+hold, pos)}
+}
+
+// Bounce simulates contact bounce as a switch is pressed or released: the
+// switch toggles every interval ms for the specified number of times, starting
+// with the new state, before settling in the new state.
+func Bounce(at uint32, pos keyboard.Pos, press bool, interval uint32, toggles int) []Op {
+	ops := make([]Op, 0, toggles+1)
+	for i := 0; i < toggles; i++ {
+		ops = append(ops, Op{At: at + uint32(i)*interval, Pos: pos, Press: press == (i%2 == 0)})
+	}
+	return append(ops, Op{At: at + uint32(toggles)*interval, Pos: pos, Press: press})
+}
+
+// Pos returns the position of a keycode in the keymap, and panics if it is not
+// there; it is meant for writing scripts against a layout such as the one
+// from modelm.ANSI101DefaultLayer.
+func Pos(keymap keyboard.Keymap, key keycodes.Keycode) keyboard.Pos {
+	pos, ok := keymap.Find(key)
+	if !ok {
+		panic("sim: keycode " + key.String() + " not in keymap")
+	}
+	return pos
+}
+
+// Matrix is a keyboard.RowReader that plays back a script of operations on
+// the switches according to the time of its clock.  If Ghosting is true, rows
+// are read the way a matrix without diodes would be, so that three switches
+// at the corners of a rectangle make the fourth one appear to be pressed.
+type Matrix struct {
+	Ghosting bool
+
+	clock   timer.Clock
+	ops     []Op
+	next    int
+	state   []keyboard.Row
+	reached []bool
+}
+
+func NewMatrix(rows uint8, clock timer.Clock, ops ...[]Op) *Matrix {
+	m := &Matrix{
+		clock:   clock,
+		state:   make([]keyboard.Row, rows),
+		reached: make([]bool, rows),
+	}
+	for _, o := range ops {
+		m.Script(o...)
+	}
+	return m
+}
+
+// Script adds operations to the script; they are kept sorted by time, with
+// operations at the same time applied in the order they were added
+func (m *Matrix) Script(ops ...Op) *Matrix {
+	m.ops = append(m.ops, ops...)
+	rest := m.ops[m.next:]
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].At < rest[j].At })
+	return m
+}
+
+// Done returns true once every operation in the script has been applied
+func (m *Matrix) Done() bool {
+	return m.next == len(m.ops)
+}
+
+// End returns the time of the last operation in the script
+func (m *Matrix) End() uint32 {
+	if len(m.ops) == 0 {
+		return 0
+	}
+	return m.ops[len(m.ops)-1].At
+}
+
+func (m *Matrix) ReadRow(rowIndex uint8) keyboard.Row {
+	m.update(timer.Millis(m.clock))
+	if int(rowIndex) >= len(m.state) {
+		return 0
+	}
+	if !m.Ghosting {
+		return m.state[rowIndex]
+	}
+	// follow the current through every row that shares a closed switch with
+	// the columns reached so far
+	for i := range m.reached {
+		m.reached[i] = false
+	}
+	cols := m.state[rowIndex]
+	m.reached[rowIndex] = true
+	for changed := true; changed; {
+		changed = false
+		for i, row := range m.state {
+			if !m.reached[i] && row&cols != 0 {
+				m.reached[i] = true
+				cols |= row
+				changed = true
+			}
+		}
+	}
+	return cols
+}
+
+func (m *Matrix) update(now uint32) {
+	for ; m.next < len(m.ops) && m.ops[m.next].At <= now; m.next++ {
+		op := m.ops[m.next]
+		if int(op.Pos.Row) >= len(m.state) {
+			continue
+		}
+		if op.Press {
+			m.state[op.Pos.Row] |= 1 << op.Pos.Col
+		} else {
+			m.state[op.Pos.Row] &^= 1 << op.Pos.Col
+		}
+	}
+}
+
+// Run calls task once per millisecond of simulated time, advancing the clock
+// after each call, until the clock reaches the specified time
+func Run(clock *timer.Fake, until uint32, task func()) {
+	for timer.Millis(clock) < until {
+		task()
+		clock.Advance(time.Millisecond)
+	}
+}
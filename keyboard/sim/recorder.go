@@ -0,0 +1,180 @@
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+type EntryKind uint8
+
+const (
+	KeyboardEntry EntryKind = iota
+	ConsumerEntry
+	SystemEntry
+	MouseEntry
+	NKROEntry
+)
+
+var entryKindNames = [...]string{
+	KeyboardEntry: "kbd",
+	ConsumerEntry: "cons",
+	SystemEntry:   "sys",
+	MouseEntry:    "mouse",
+	NKROEntry:     "nkro",
+}
+
+func (kind EntryKind) String() string {
+	if int(kind) < len(entryKindNames) {
+		return entryKindNames[kind]
+	}
+	return "?"
+}
+
+// Entry is a report received by a Recorder, along with the time in ms that
+// it was received
+type Entry struct {
+	Time   uint32
+	Kind   EntryKind
+	Report keyboard.Report
+	Usage  uint16
+	Mouse  keyboard.MouseReport
+	NKRO   keyboard.NKROReport
+}
+
+func (e *Entry) String() string {
+	var s string
+	switch e.Kind {
+	case KeyboardEntry:
+		s = e.Report.String()
+	case ConsumerEntry, SystemEntry:
+		s = fmt.Sprintf("%04X", e.Usage)
+	case MouseEntry:
+		s = e.Mouse.String()
+	case NKROEntry:
+		s = e.NKRO.String()
+	}
+	return fmt.Sprintf("%8d %-5s %s", e.Time, e.Kind.String(), s)
+}
+
+// Recorder is a keyboard.Host that keeps a trace of every report it receives,
+// which can be compared against a golden file.  It also implements
+// keyboard.NKROHost; set Boot to simulate a host using the boot protocol.  As
+// a keyboard.LEDHost it returns the LED state injected with SetLEDs.
+type Recorder struct {
+	Boot bool
+
+	clock   timer.Clock
+	entries []Entry
+	leds    keyboard.LED
+}
+
+func NewRecorder(clock timer.Clock) *Recorder {
+	return &Recorder{clock: clock}
+}
+
+func (r *Recorder) Send(report *keyboard.Report) {
+	r.add(Entry{Kind: KeyboardEntry, Report: *report})
+}
+
+func (r *Recorder) SendConsumer(usage keyboard.ConsumerUsage) {
+	r.add(Entry{Kind: ConsumerEntry, Usage: uint16(usage)})
+}
+
+func (r *Recorder) SendSystem(usage keyboard.SystemUsage) {
+	r.add(Entry{Kind: SystemEntry, Usage: uint16(usage)})
+}
+
+func (r *Recorder) SendMouse(report *keyboard.MouseReport) {
+	r.add(Entry{Kind: MouseEntry, Mouse: *report})
+}
+
+func (r *Recorder) SendNKRO(report *keyboard.NKROReport) {
+	r.add(Entry{Kind: NKROEntry, NKRO: *report})
+}
+
+func (r *Recorder) BootProtocol() bool {
+	return r.Boot
+}
+
+func (r *Recorder) LEDs() keyboard.LED {
+	return r.leds
+}
+
+func (r *Recorder) SetLEDs(leds keyboard.LED) {
+	r.leds = leds
+}
+
+func (r *Recorder) add(e Entry) {
+	e.Time = timer.Millis(r.clock)
+	r.entries = append(r.entries, e)
+}
+
+func (r *Recorder) Entries() []Entry {
+	return r.entries
+}
+
+func (r *Recorder) Reset() {
+	r.entries = r.entries[:0]
+}
+
+// String returns the trace with one entry per line
+func (r *Recorder) String() string {
+	var buf bytes.Buffer
+	r.WriteTo(&buf)
+	return buf.String()
+}
+
+func (r *Recorder) WriteTo(w io.Writer) (n int64, err error) {
+	for i := range r.entries {
+		c, err := fmt.Fprintln(w, r.entries[i].String())
+		n += int64(c)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// CompareGolden compares the trace against the contents of the file at path,
+// returning an error that describes the first difference if they do not
+// match.  If update is true the file is overwritten with the trace instead.
+func (r *Recorder) CompareGolden(path string, update bool) error {
+	got := r.String()
+	if update {
+		return os.WriteFile(path, []byte(got), 0644)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	want := strings.ReplaceAll(string(b), "\r\n", "\n")
+	if got == want {
+		return nil
+	}
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+	n := len(gotLines)
+	if len(wantLines) > n {
+		n = len(wantLines)
+	}
+	for i := 0; i < n; i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Errorf("%s:%d: trace differs from golden file\n got: %q\nwant: %q", path, i+1, g, w)
+		}
+	}
+	// the traces only differ in lines that are empty
+	return fmt.Errorf("%s: trace has %d lines, golden file has %d", path, len(gotLines), len(wantLines))
+}
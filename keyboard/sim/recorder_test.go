@@ -0,0 +1,104 @@
+package sim_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func recorded() *sim.Recorder {
+	clock := timer.NewFake()
+	r := sim.NewRecorder(clock)
+	var report keyboard.Report
+	clock.Advance(10 * time.Millisecond)
+	r.Send(report.Keyboard(keyboard.KbdModShiftLeft, 0x04))
+	clock.Advance(10 * time.Millisecond)
+	r.Send(report.Keyboard(0))
+	r.SendConsumer(keyboard.ConsumerUsage(0xE9))
+	clock.Advance(5 * time.Millisecond)
+	r.SendConsumer(0)
+	return r
+}
+
+func TestRecorderGolden(t *testing.T) {
+	if err := recorded().CompareGolden("testdata/recorder.golden", *update); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecorderCompareGolden(t *testing.T) {
+	want, err := os.ReadFile("testdata/recorder.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(want), "\n")
+	tests := []struct {
+		name   string
+		golden string
+		err    string
+	}{
+		{
+			name:   "same",
+			golden: string(want),
+		},
+		{
+			name:   "crlf line endings",
+			golden: strings.ReplaceAll(string(want), "\n", "\r\n"),
+		},
+		{
+			name:   "different line",
+			golden: lines[0] + strings.Replace(lines[1], "20", "21", 1) + strings.Join(lines[2:], ""),
+			err:    ":2: trace differs",
+		},
+		{
+			name:   "missing line",
+			golden: strings.Join(lines[:2], ""),
+			err:    ":3: trace differs",
+		},
+		{
+			name:   "trailing empty lines",
+			golden: string(want) + "\n\n",
+			err:    "trace has 5 lines, golden file has 7",
+		},
+		{
+			name:   "empty",
+			golden: "",
+			err:    ":1: trace differs",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "trace.golden")
+			if err := os.WriteFile(path, []byte(tt.golden), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := recorded().CompareGolden(path, false)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRecorderUpdateGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.golden")
+	r := recorded()
+	if err := r.CompareGolden(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CompareGolden(path, false); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package keyboard
+
+import (
+	"fmt"
+)
+
+const (
+	TappingTerm = 200
+
+	tapHoldKeysSize = 8
+)
+
+// TapHoldConfig controls how a tap-hold key (ActionModTap or ActionLayerTap)
+// decides between a tap and a hold.  By default the key is a hold only if it
+// is still down after TappingTerm ms.
+//
+// PermissiveHold selects the hold if another key is pressed and released
+// while the tap-hold key is down, even within the tapping term.
+//
+// HoldOnOtherKeyPress selects the hold as soon as another key is pressed.
+//
+// RetroTapping sends the tap if the key is released after the tapping term
+// without any other key having been pressed in the meantime.
+type TapHoldConfig struct {
+	TappingTerm         uint16
+	PermissiveHold      bool
+	HoldOnOtherKeyPress bool
+	RetroTapping        bool
+}
+
+func DefaultTapHoldConfig() TapHoldConfig {
+	return TapHoldConfig{TappingTerm: TappingTerm}
+}
+
+type tapHoldKey struct {
+	pos         Pos
+	action      Action
+	active      bool
+	tapped      bool
+	interrupted bool
+}
+
+type tapHold struct {
+	config TapHoldConfig
+
+	// the press of the undecided key
+	pending bool
+	press   Event
+	action  Action
+
+	// keys that have been decided and are still held down
+	keys [tapHoldKeysSize]tapHoldKey
+}
+
+func (kbd *Keyboard) WithTapHold(config TapHoldConfig) *Keyboard {
+	kbd.tapping.config = config
+	return kbd
+}
+
+// decide looks ahead at the queued events that follow the press of the pending
+// key, as well as the current time, and returns whether the key has been
+// decided and if so whether it is a hold.
+func (t *tapHold) decide(events *EventQueue, now uint32) (decided bool, hold bool) {
+	term := uint32(t.config.TappingTerm)
+	for i := 0; i < events.Len(); i++ {
+		ev, _ := events.Peek(i)
+		if ev.Time-t.press.Time >= term {
+			return true, true
+		}
+		if ev.Pos == t.press.Pos {
+			return true, false
+		}
+		if ev.Made {
+			if t.config.HoldOnOtherKeyPress {
+				return true, true
+			}
+		} else if t.config.PermissiveHold && pressedBefore(events, ev.Pos, i) {
+			return true, true
+		}
+	}
+	if now-t.press.Time >= term {
+		return true, true
+	}
+	return false, false
+}
+
+// pressedBefore returns true if the key at pos was pressed before the i-th
+// queued event
+func pressedBefore(events *EventQueue, pos Pos, i int) bool {
+	for j := 0; j < i; j++ {
+		if ev, _ := events.Peek(j); ev.Pos == pos && ev.Made {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tapHold) find(pos Pos) *tapHoldKey {
+	for i := range t.keys {
+		if t.keys[i].active && t.keys[i].pos == pos {
+			return &t.keys[i]
+		}
+	}
+	return nil
+}
+
+func (t *tapHold) add(pos Pos, action Action, tapped bool) *tapHoldKey {
+	for i := range t.keys {
+		if !t.keys[i].active {
+			t.keys[i] = tapHoldKey{pos: pos, action: action, active: true, tapped: tapped}
+			return &t.keys[i]
+		}
+	}
+	return nil
+}
+
+// interrupt marks the held tap-hold keys other than the one at pos as having
+// been used together with another key
+func (t *tapHold) interrupt(pos Pos) {
+	for i := range t.keys {
+		if t.keys[i].active && t.keys[i].pos != pos {
+			t.keys[i].interrupted = true
+		}
+	}
+}
+
+func (kbd *Keyboard) processTapHold(action Action, ev Event) {
+	if ev.Made {
+		kbd.tapping.pending = true
+		kbd.tapping.press = ev
+		kbd.tapping.action = action
+		return
+	}
+	key := kbd.tapping.find(ev.Pos)
+	if key == nil {
+		return
+	}
+	key.active = false
+	if key.tapped {
+		kbd.processKey(action.Key, ev)
+		return
+	}
+	kbd.releaseHold(action)
+	if kbd.tapping.config.RetroTapping && !key.interrupted {
+		if kbd.debug {
+			fmt.Fprintf(kbd.console, "tap-hold => retro tap\r\n")
+		}
+		kbd.processKey(action.Key, Event{Pos: ev.Pos, Made: true, Time: ev.Time})
+		kbd.processKey(action.Key, ev)
+	}
+}
+
+func (kbd *Keyboard) resolveTapHold(hold bool) {
+	t := &kbd.tapping
+	t.pending = false
+	if kbd.debug {
+		fmt.Fprintf(kbd.console, "tap-hold => code: %X%X, hold: %t\r\n",
+			t.press.Pos.Row, t.press.Pos.Col, hold)
+	}
+	if t.add(t.press.Pos, t.action, !hold) == nil {
+		// no room to track the key until it is released, so just tap it
+		kbd.processKey(t.action.Key, t.press)
+		kbd.processKey(t.action.Key, Event{Pos: t.press.Pos, Time: t.press.Time})
+		return
+	}
+	if hold {
+		kbd.applyHold(t.action)
+	} else {
+		kbd.processKey(t.action.Key, t.press)
+	}
+}
+
+func (kbd *Keyboard) applyHold(action Action) {
+	switch action.Kind {
+	case ActModTap:
+		kbd.setMods(action.Mods, true)
+	case ActLayerTap:
+		kbd.LayerOn(action.Layer)
+	}
+}
+
+func (kbd *Keyboard) releaseHold(action Action) {
+	switch action.Kind {
+	case ActModTap:
+		kbd.setMods(action.Mods, false)
+	case ActLayerTap:
+		kbd.LayerOff(action.Layer)
+	}
+}
@@ -0,0 +1,141 @@
+package keyboard_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/sim"
+)
+
+var (
+	posModTap = keyboard.Pos{Row: 0, Col: 0}
+	posOther  = keyboard.Pos{Row: 0, Col: 1}
+)
+
+// tapHoldTestRig has a mod-tap key (LSFT when held, ESC when tapped) next to
+// A, in a single row so that the keys cannot ghost
+func tapHoldTestRig(config keyboard.TapHoldConfig, ops ...[]sim.Op) *testRig {
+	layers := []keyboard.Keymap{{
+		{keycodes.FN0, keycodes.A},
+	}}
+	r := newTestRig(layers, ops...)
+	r.kbd.WithTapHold(config).WithActions([]keyboard.Action{
+		keyboard.ActionModTap(keyboard.KbdModShiftLeft, keycodes.ESC),
+	})
+	return r
+}
+
+// reports returns the modifiers and first two keys of each keyboard report,
+// along with the time it was sent
+func (r *testRig) reports() []string {
+	var s []string
+	for _, e := range r.host.Entries() {
+		if e.Kind == sim.KeyboardEntry {
+			s = append(s, fmt.Sprintf("%d: %02X %02X %02X", e.Time, e.Report[0], e.Report[2], e.Report[3]))
+		}
+	}
+	return s
+}
+
+func TestTapHold(t *testing.T) {
+	const (
+		shift = byte(keyboard.KbdModShiftLeft)
+		esc   = keycodes.ESC
+		a     = keycodes.A
+	)
+	report := func(at uint32, mods byte, keys ...keycodes.Keycode) string {
+		var k [2]keycodes.Keycode
+		copy(k[:], keys)
+		return fmt.Sprintf("%d: %02X %02X %02X", at, mods, uint8(k[0]), uint8(k[1]))
+	}
+	permissive := keyboard.DefaultTapHoldConfig()
+	permissive.PermissiveHold = true
+	eager := keyboard.DefaultTapHoldConfig()
+	eager.HoldOnOtherKeyPress = true
+	retro := keyboard.DefaultTapHoldConfig()
+	retro.RetroTapping = true
+
+	tests := []struct {
+		name   string
+		config keyboard.TapHoldConfig
+		ops    [][]sim.Op
+		want   []string
+	}{
+		{
+			name:   "tap",
+			config: keyboard.DefaultTapHoldConfig(),
+			ops:    [][]sim.Op{sim.Tap(10, 50, posModTap)},
+			want:   []string{report(60, 0, esc), report(60, 0)},
+		},
+		{
+			name:   "hold",
+			config: keyboard.DefaultTapHoldConfig(),
+			ops:    [][]sim.Op{sim.Tap(10, 300, posModTap)},
+			want:   []string{report(210, shift), report(310, 0)},
+		},
+		{
+			name:   "released one ms before the tapping term",
+			config: keyboard.DefaultTapHoldConfig(),
+			ops:    [][]sim.Op{sim.Tap(10, keyboard.TappingTerm-1, posModTap)},
+			want:   []string{report(209, 0, esc), report(209, 0)},
+		},
+		{
+			name:   "released at the tapping term",
+			config: keyboard.DefaultTapHoldConfig(),
+			ops:    [][]sim.Op{sim.Tap(10, keyboard.TappingTerm, posModTap)},
+			want:   []string{report(210, shift), report(210, 0)},
+		},
+		{
+			name:   "other key tapped inside the tapping term",
+			config: keyboard.DefaultTapHoldConfig(),
+			ops:    [][]sim.Op{sim.Tap(10, 100, posModTap), sim.Tap(30, 40, posOther)},
+			want: []string{
+				report(110, 0, esc), report(110, 0, esc, a), report(110, 0, esc), report(110, 0),
+			},
+		},
+		{
+			name:   "other key tapped with permissive hold",
+			config: permissive,
+			ops:    [][]sim.Op{sim.Tap(10, 100, posModTap), sim.Tap(30, 40, posOther)},
+			want: []string{
+				report(70, shift), report(70, shift, a), report(70, shift), report(110, 0),
+			},
+		},
+		{
+			name:   "other key pressed with hold on other key press",
+			config: eager,
+			ops:    [][]sim.Op{sim.Tap(10, 100, posModTap), sim.Tap(30, 40, posOther)},
+			want: []string{
+				report(30, shift), report(30, shift, a), report(70, shift), report(110, 0),
+			},
+		},
+		{
+			name:   "retro tap",
+			config: retro,
+			ops:    [][]sim.Op{sim.Tap(10, 300, posModTap)},
+			want: []string{
+				report(210, shift), report(310, 0), report(310, 0, esc), report(310, 0),
+			},
+		},
+		{
+			name:   "retro tap interrupted by another key",
+			config: retro,
+			ops:    [][]sim.Op{sim.Tap(10, 300, posModTap), sim.Tap(250, 20, posOther)},
+			want: []string{
+				report(210, shift), report(250, shift, a), report(270, shift), report(310, 0),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tapHoldTestRig(tt.config, tt.ops...)
+			r.run(400)
+			got := r.reports()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got reports:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+//go:build linux
+// +build linux
+
+package uinput
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctl requests from linux/uinput.h
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405C5503
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetLedBit  = 0x40045569
+
+	busVirtual = 0x06
+	maxNameLen = 80
+)
+
+// uinputSetup mirrors struct uinput_setup
+type uinputSetup struct {
+	bustype      uint16
+	vendor       uint16
+	product      uint16
+	version      uint16
+	name         [maxNameLen]byte
+	ffEffectsMax uint32
+}
+
+// Device is a virtual input device created through /dev/uinput
+type Device struct {
+	file *os.File
+	fd   int
+	buf  []byte
+	rbuf []byte
+}
+
+// Open creates a virtual keyboard and mouse device with the specified name and
+// returns a host that sends its events to it; the device is destroyed when
+// the host is closed.
+func Open(name string) (*Host, error) {
+	dev, err := OpenDevice(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(dev), nil
+}
+
+func OpenDevice(name string) (*Device, error) {
+	file, err := os.OpenFile("/dev/uinput", os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	dev := &Device{
+		file: file,
+		buf:  make([]byte, unsafe.Sizeof(syscall.Timeval{})+8),
+		rbuf: make([]byte, unsafe.Sizeof(syscall.Timeval{})+8),
+	}
+	if err := dev.setup(name); err != nil {
+		file.Close()
+		return nil, err
+	}
+	// Fd puts the file in blocking mode, so read from the descriptor directly
+	// to be able to poll for events
+	dev.fd = int(file.Fd())
+	if err := syscall.SetNonblock(dev.fd, true); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return dev, nil
+}
+
+func (dev *Device) setup(name string) error {
+	if err := dev.ioctl(uiSetEvBit, EvKey); err != nil {
+		return err
+	}
+	if err := dev.ioctl(uiSetEvBit, EvRel); err != nil {
+		return err
+	}
+	if err := dev.ioctl(uiSetEvBit, EvLed); err != nil {
+		return err
+	}
+	// enable all of the keyboard keys as well as the mouse buttons
+	for code := uintptr(1); code <= BtnExtra; code++ {
+		if code > 0xFF && code < BtnLeft {
+			continue
+		}
+		if err := dev.ioctl(uiSetKeyBit, code); err != nil {
+			return err
+		}
+	}
+	for _, code := range []uintptr{RelX, RelY, RelWheel, RelHWheel} {
+		if err := dev.ioctl(uiSetRelBit, code); err != nil {
+			return err
+		}
+	}
+	for code := uintptr(LedNumL); code <= LedKana; code++ {
+		if err := dev.ioctl(uiSetLedBit, code); err != nil {
+			return err
+		}
+	}
+	setup := uinputSetup{bustype: busVirtual, vendor: 0x1209, product: 0x0001, version: 1}
+	copy(setup.name[:maxNameLen-1], name)
+	if err := dev.ioctl(uiDevSetup, uintptr(unsafe.Pointer(&setup))); err != nil {
+		return err
+	}
+	return dev.ioctl(uiDevCreate, 0)
+}
+
+// WriteEvent writes a struct input_event; the kernel fills in the timestamp
+func (dev *Device) WriteEvent(typ uint16, code uint16, value int32) error {
+	n := len(dev.buf) - 8
+	for i := range dev.buf[:n] {
+		dev.buf[i] = 0
+	}
+	binary.NativeEndian.PutUint16(dev.buf[n:], typ)
+	binary.NativeEndian.PutUint16(dev.buf[n+2:], code)
+	binary.NativeEndian.PutUint32(dev.buf[n+4:], uint32(value))
+	_, err := dev.file.Write(dev.buf)
+	return err
+}
+
+// ReadEvent reads a struct input_event sent to the device by the kernel, such
+// as an LED change, without blocking
+func (dev *Device) ReadEvent() (typ uint16, code uint16, value int32, ok bool) {
+	if n, err := syscall.Read(dev.fd, dev.rbuf); err != nil || n < len(dev.rbuf) {
+		return 0, 0, 0, false
+	}
+	n := len(dev.rbuf) - 8
+	typ = binary.NativeEndian.Uint16(dev.rbuf[n:])
+	code = binary.NativeEndian.Uint16(dev.rbuf[n+2:])
+	value = int32(binary.NativeEndian.Uint32(dev.rbuf[n+4:]))
+	return typ, code, value, true
+}
+
+func (dev *Device) Close() error {
+	dev.ioctl(uiDevDestroy, 0)
+	return dev.file.Close()
+}
+
+func (dev *Device) ioctl(req uintptr, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dev.file.Fd(), req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package uinput
+
+import (
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+// keyCodes maps HID Keyboard/Keypad page usages to Linux key codes, following
+// the hid_keyboard table in drivers/hid/hid-input.c
+var keyCodes = [...]uint16{
+	/* 0x00 */ 0, 0, 0, 0, 30, 48, 46, 32, 18, 33, 34, 35, 23, 36, 37, 38,
+	/* 0x10 */ 50, 49, 24, 25, 16, 19, 31, 20, 22, 47, 17, 45, 21, 44, 2, 3,
+	/* 0x20 */ 4, 5, 6, 7, 8, 9, 10, 11, 28, 1, 14, 15, 57, 12, 13, 26,
+	/* 0x30 */ 27, 43, 43, 39, 40, 41, 51, 52, 53, 58, 59, 60, 61, 62, 63, 64,
+	/* 0x40 */ 65, 66, 67, 68, 87, 88, 99, 70, 119, 110, 102, 104, 111, 107, 109, 106,
+	/* 0x50 */ 105, 108, 103, 69, 98, 55, 74, 78, 96, 79, 80, 81, 75, 76, 77, 71,
+	/* 0x60 */ 72, 73, 82, 83, 86, 127, 116, 117, 183, 184, 185, 186, 187, 188, 189, 190,
+	/* 0x70 */ 191, 192, 193, 194, 134, 138, 130, 132, 128, 129, 131, 137, 133, 135, 136, 113,
+	/* 0x80 */ 115, 114, 0, 0, 0, 121, 0, 89, 93, 124, 92, 94, 95, 0, 0, 0,
+	/* 0x90 */ 122, 123, 90, 91, 85,
+}
+
+// modifierCodes maps the modifier usages 0xE0-0xE7 to Linux key codes
+var modifierCodes = [...]uint16{29, 42, 56, 125, 97, 54, 100, 126}
+
+// KeyCode returns the Linux key code for a keycode from the HID Keyboard page,
+// or 0 if there is none.
+func KeyCode(key keycodes.Keycode) uint16 {
+	if key.IsModifier() {
+		return modifierCodes[key-keycodes.LCTRL]
+	}
+	if int(key) < len(keyCodes) {
+		return keyCodes[key]
+	}
+	return 0
+}
+
+// ConsumerKeyCode returns the Linux key code for a Consumer page usage, or 0
+// if there is none.
+func ConsumerKeyCode(usage keyboard.ConsumerUsage) uint16 {
+	switch usage {
+	case keyboard.ConsumerMute:
+		return 113
+	case keyboard.ConsumerVolumeUp:
+		return 115
+	case keyboard.ConsumerVolumeDown:
+		return 114
+	case keyboard.ConsumerNextTrack:
+		return 163
+	case keyboard.ConsumerPrevTrack:
+		return 165
+	case keyboard.ConsumerFastForward:
+		return 208
+	case keyboard.ConsumerRewind:
+		return 168
+	case keyboard.ConsumerStop:
+		return 166
+	case keyboard.ConsumerEject:
+		return 161
+	case keyboard.ConsumerPlayPause:
+		return 164
+	case keyboard.ConsumerMediaSelect:
+		return 226
+	case keyboard.ConsumerMail:
+		return 155
+	case keyboard.ConsumerCalculator:
+		return 140
+	case keyboard.ConsumerMyComputer:
+		return 157
+	case keyboard.ConsumerWWWSearch:
+		return 217
+	case keyboard.ConsumerWWWHome:
+		return 172
+	case keyboard.ConsumerWWWBack:
+		return 158
+	case keyboard.ConsumerWWWForward:
+		return 159
+	case keyboard.ConsumerWWWStop:
+		return 128
+	case keyboard.ConsumerWWWRefresh:
+		return 173
+	case keyboard.ConsumerWWWFavorites:
+		return 156
+	}
+	return 0
+}
+
+// SystemKeyCode returns the Linux key code for a System Control usage, or 0 if
+// there is none.
+func SystemKeyCode(usage keyboard.SystemUsage) uint16 {
+	switch usage {
+	case keyboard.SystemPower:
+		return 116
+	case keyboard.SystemSleep:
+		return 142
+	case keyboard.SystemWakeUp:
+		return 143
+	}
+	return 0
+}
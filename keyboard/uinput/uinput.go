@@ -0,0 +1,249 @@
+// Package uinput implements a keyboard.Host that turns reports into Linux
+// input events, so that the keyboard stack can be run on a workstation.
+package uinput
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+)
+
+// Event types and codes from linux/input-event-codes.h
+const (
+	EvSyn = 0x00
+	EvKey = 0x01
+	EvRel = 0x02
+	EvLed = 0x11
+
+	SynReport = 0x00
+
+	RelX      = 0x00
+	RelY      = 0x01
+	RelHWheel = 0x06
+	RelWheel  = 0x08
+
+	BtnLeft   = 0x110
+	BtnRight  = 0x111
+	BtnMiddle = 0x112
+	BtnSide   = 0x113
+	BtnExtra  = 0x114
+
+	LedNumL    = 0x00
+	LedCapsL   = 0x01
+	LedScrollL = 0x02
+	LedCompose = 0x03
+	LedKana    = 0x04
+)
+
+// EventWriter is the destination for the input events generated by Host
+type EventWriter interface {
+	WriteEvent(typ uint16, code uint16, value int32) error
+}
+
+// EventReader is implemented by event writers that also receive events, such
+// as a Device that the kernel sends the LED state to.  ReadEvent returns
+// false if there is no event pending.
+type EventReader interface {
+	ReadEvent() (typ uint16, code uint16, value int32, ok bool)
+}
+
+// Host translates the differences between successive reports into key press
+// and release events, and mouse reports into relative movement events.  The
+// first error from the EventWriter is kept and can be checked with Err.
+type Host struct {
+	out      EventWriter
+	prev     keyboard.Report
+	prevNKRO keyboard.NKROReport
+	consumer uint16
+	system   uint16
+	buttons  keyboard.MouseButton
+	leds     keyboard.LED
+	err      error
+}
+
+func New(out EventWriter) *Host {
+	return &Host{out: out}
+}
+
+// NewDryRun returns a host that writes a line of text for each input event to
+// w instead of creating a device, e.g. for CI machines without uinput.
+func NewDryRun(w io.Writer) *Host {
+	return New(&textWriter{w: w})
+}
+
+func (host *Host) Err() error {
+	return host.err
+}
+
+// Close closes the EventWriter if it is an io.Closer, such as a Device
+func (host *Host) Close() error {
+	if c, ok := host.out.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (host *Host) Send(report *keyboard.Report) {
+	if mods := report[0] ^ host.prev[0]; mods != 0 {
+		for i := uint8(0); i < 8; i++ {
+			if mods&(1<<i) > 0 {
+				host.key(KeyCode(keycodes.LCTRL+keycodes.Keycode(i)), report[0]&(1<<i) > 0)
+			}
+		}
+	}
+	if !isRollOver(report) {
+		for _, c := range host.prev[2:] {
+			if c != 0 && !contains(report[2:], c) {
+				host.key(KeyCode(keycodes.Keycode(c)), false)
+			}
+		}
+		for _, c := range report[2:] {
+			if c != 0 && !contains(host.prev[2:], c) {
+				host.key(KeyCode(keycodes.Keycode(c)), true)
+			}
+		}
+		copy(host.prev[2:], report[2:])
+	}
+	host.prev[0] = report[0]
+	host.sync()
+}
+
+// BootProtocol always returns false, since uinput has no report size limit
+func (host *Host) BootProtocol() bool {
+	return false
+}
+
+func (host *Host) SendNKRO(report *keyboard.NKROReport) {
+	for i, b := range report {
+		diff := b ^ host.prevNKRO[i]
+		for j := 0; diff != 0 && j < 8; j++ {
+			if diff&(1<<j) > 0 {
+				host.key(KeyCode(keycodes.Keycode(i*8+j)), b&(1<<j) > 0)
+			}
+		}
+	}
+	host.prevNKRO = *report
+	host.sync()
+}
+
+// LEDs reads any pending LED events if the EventWriter is also an
+// EventReader, and returns the LED state; the LED codes in Linux have the same
+// bit positions as in the HID output report.
+func (host *Host) LEDs() keyboard.LED {
+	in, ok := host.out.(EventReader)
+	if !ok {
+		return host.leds
+	}
+	for {
+		typ, code, value, ok := in.ReadEvent()
+		if !ok {
+			return host.leds
+		}
+		if typ != EvLed || code > LedKana {
+			continue
+		}
+		if value != 0 {
+			host.leds |= 1 << code
+		} else {
+			host.leds &^= 1 << code
+		}
+	}
+}
+
+func (host *Host) SendConsumer(usage keyboard.ConsumerUsage) {
+	host.consumer = host.usage(host.consumer, ConsumerKeyCode(usage))
+}
+
+func (host *Host) SendSystem(usage keyboard.SystemUsage) {
+	host.system = host.usage(host.system, SystemKeyCode(usage))
+}
+
+// usage releases the previous key if there is one, and presses the new one
+func (host *Host) usage(prev uint16, code uint16) uint16 {
+	if prev == code {
+		return code
+	}
+	if prev != 0 {
+		host.key(prev, false)
+	}
+	if code != 0 {
+		host.key(code, true)
+	}
+	host.sync()
+	return code
+}
+
+func (host *Host) SendMouse(report *keyboard.MouseReport) {
+	if buttons := report.Buttons ^ host.buttons; buttons != 0 {
+		for i := uint16(0); i < 5; i++ {
+			if buttons&(1<<i) > 0 {
+				host.key(BtnLeft+i, report.Buttons&(1<<i) > 0)
+			}
+		}
+		host.buttons = report.Buttons
+	}
+	host.rel(RelX, report.X)
+	host.rel(RelY, report.Y)
+	host.rel(RelWheel, report.V)
+	host.rel(RelHWheel, report.H)
+	host.sync()
+}
+
+func (host *Host) key(code uint16, pressed bool) {
+	if code == 0 {
+		return
+	}
+	value := int32(0)
+	if pressed {
+		value = 1
+	}
+	host.write(EvKey, code, value)
+}
+
+func (host *Host) rel(code uint16, value int8) {
+	if value != 0 {
+		host.write(EvRel, code, int32(value))
+	}
+}
+
+func (host *Host) sync() {
+	host.write(EvSyn, SynReport, 0)
+}
+
+func (host *Host) write(typ uint16, code uint16, value int32) {
+	if err := host.out.WriteEvent(typ, code, value); err != nil && host.err == nil {
+		host.err = err
+	}
+}
+
+// isRollOver returns true if the report signals that too many keys are down,
+// in which case the key array is left alone until it is valid again
+func isRollOver(report *keyboard.Report) bool {
+	return keycodes.Keycode(report[2]) == keycodes.ROLL_OVER
+}
+
+func contains(keys []byte, c byte) bool {
+	for _, k := range keys {
+		if k == c {
+			return true
+		}
+	}
+	return false
+}
+
+type textWriter struct {
+	w io.Writer
+}
+
+var typeNames = [...]string{EvSyn: "EV_SYN", EvKey: "EV_KEY", EvRel: "EV_REL"}
+
+func (tw *textWriter) WriteEvent(typ uint16, code uint16, value int32) error {
+	name := "EV_UNKNOWN"
+	if int(typ) < len(typeNames) {
+		name = typeNames[typ]
+	}
+	_, err := fmt.Fprintf(tw.w, "%s %d %d\n", name, code, value)
+	return err
+}
@@ -0,0 +1,93 @@
+package uinput_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keycodes"
+	"github.com/bgould/tinygo-model-m/keyboard/uinput"
+)
+
+// Linux key codes used by the tests
+const (
+	keyA      = "30"
+	keyB      = "48"
+	keyLShift = "42"
+)
+
+func press(code string) string   { return "EV_KEY " + code + " 1" }
+func release(code string) string { return "EV_KEY " + code + " 0" }
+
+const syn = "EV_SYN 0 0"
+
+// events returns the lines written to buf since the last call
+func events(buf *bytes.Buffer) []string {
+	s := strings.TrimSpace(buf.String())
+	buf.Reset()
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func checkEvents(t *testing.T, step string, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("%s: got events %q, want %q", step, got, want)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var buf bytes.Buffer
+	host := uinput.NewDryRun(&buf)
+	var report keyboard.Report
+	a, b := byte(keycodes.A), byte(keycodes.B)
+
+	host.Send(report.Keyboard(0, a))
+	checkEvents(t, "press A", events(&buf), []string{press(keyA), syn})
+
+	host.Send(report.Keyboard(keyboard.KbdModShiftLeft, a, b))
+	checkEvents(t, "shift and B", events(&buf), []string{press(keyLShift), press(keyB), syn})
+
+	// the key array of a rollover report is skipped, but the modifiers are not
+	rollOver := byte(keycodes.ROLL_OVER)
+	host.Send(report.Keyboard(0, rollOver, rollOver, rollOver, rollOver, rollOver, rollOver))
+	checkEvents(t, "rollover", events(&buf), []string{release(keyLShift), syn})
+
+	// the keys are compared with the last report that was not a rollover
+	host.Send(report.Keyboard(0, b))
+	checkEvents(t, "release A", events(&buf), []string{release(keyA), syn})
+
+	host.Send(report.Keyboard(0))
+	checkEvents(t, "release B", events(&buf), []string{release(keyB), syn})
+
+	if err := host.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendNKRO(t *testing.T) {
+	var buf bytes.Buffer
+	host := uinput.NewDryRun(&buf)
+	var report keyboard.NKROReport
+
+	report.Make(keycodes.A)
+	report.Make(keycodes.LSHIFT)
+	host.SendNKRO(&report)
+	checkEvents(t, "press", events(&buf), []string{press(keyA), press(keyLShift), syn})
+
+	report.Break(keycodes.A)
+	report.Make(keycodes.B)
+	host.SendNKRO(&report)
+	checkEvents(t, "change", events(&buf), []string{release(keyA), press(keyB), syn})
+
+	host.SendNKRO(&report)
+	checkEvents(t, "repeat", events(&buf), []string{syn})
+
+	report.Break(keycodes.B)
+	report.Break(keycodes.LSHIFT)
+	host.SendNKRO(&report)
+	checkEvents(t, "release", events(&buf), []string{release(keyB), release(keyLShift), syn})
+}
@@ -6,6 +6,7 @@ import (
 
 	m "machine"
 
+	"github.com/bgould/tinygo-model-m/battery"
 	"github.com/bgould/tinygo-model-m/bluefruit/ble"
 	"github.com/bgould/tinygo-model-m/bluefruit/ezkey"
 	"github.com/bgould/tinygo-model-m/keyboard"
@@ -36,6 +37,24 @@ var (
 	//       a port that could be used to read these in a single operation
 	pins = []m.Pin{m.A0, m.A1, m.A2, m.A3, m.D11, m.D10, m.D9, m.D6}
 
+	// indicator pins for Num Lock, Caps Lock and Scroll Lock; set these to the
+	// pins that the LEDs are wired to, if any
+	ledPins = [3]m.Pin{m.NoPin, m.NoPin, m.NoPin}
+
+	// connection indicator, lit while a central is connected
+	connPin = m.LED
+
+	// the VBAT pin of the Feather M0 (A7) is also D9, which strobes a row of
+	// the matrix, so battery reporting is off unless it is wired elsewhere
+	batteryPin = m.NoPin
+	batteryMon *battery.Monitor
+
+	// keymapVersion is reported over BLE so that a configuration app can tell
+	// which keymap is installed; bump it whenever the keymap changes
+	keymapVersion = [2]byte{0, 1}
+
+	clock = timer.System
+
 	kbd *keyboard.Keyboard
 )
 
@@ -46,20 +65,41 @@ func main() {
 
 	spi.Configure(m.SPIConfig{LSBFirst: false, Frequency: 1e6})
 	spifriend := ble.NewSPIFriend(spi, csPin, irqPin, m.NoPin)
-	spifriend.Begin(ble.SPIFriendConfig{Verbose: false})
-
-	host := &BluefruitLEHost{spifriend}
-	host.Init()
+	spifriend.Begin(ble.SPIFriendConfig{Verbose: false, Clock: clock})
+	spifriend.SetAlertHandler(func(alert ble.Alert) {
+		debug("bluefruit alert: %04X [ % X ]\r\n", alert.ID, alert.Payload)
+	})
+
+	host := &BluefruitLEHost{
+		spifriend: spifriend,
+		profiles:  ble.NewProfiles(spifriend),
+	}
+	host.conn = ble.NewConnection(spifriend).WithCallbacks(host.connected, host.disconnected)
+	if batteryPin != m.NoPin {
+		// VBAT is halved by a divider and the ADC reference is 3.3V
+		batteryMon = battery.NewMonitor(battery.NewADC(batteryPin, 2, 3300)).
+			WithClock(clock).WithLevelHook(host.setBatteryLevel).WithLowBatteryHook(host.lowBattery)
+	}
+	if err := host.Init(); err != nil {
+		fmt.Fprintf(console, "bluefruit init failed: %s\r\n", err.Error())
+	}
 
-	matrix := keyboard.NewMatrix(keyboard.RowReaderFunc(ReadRow))
+	matrix := keyboard.NewMatrix(modelm.MatrixRows, modelm.MatrixCols,
+		keyboard.RowReaderFunc(ReadRow), clock, keyboard.NewSymDeferGlobal(keyboard.DebounceMS))
 	layers := []keyboard.Keymap{modelm.ANSI101DefaultLayer()}
-	kbd = keyboard.New(console, host, matrix, layers).WithDebug(_debug)
+	kbd = keyboard.New(console, host, matrix, layers).WithClock(clock).WithDebug(_debug).
+		WithLEDHook(setIndicators)
 
 	configurePins()
 	configurePortExpanders()
 
 	for {
 		kbd.Task()
+		host.Task()
+		if batteryMon != nil {
+			batteryMon.Task()
+		}
+		host.SetStatus((kbd.LayerState() | kbd.DefaultLayerState()).Highest(), kbd.LEDs())
 		//time.Sleep(500 * time.Microsecond)
 	}
 
@@ -71,6 +111,23 @@ func configurePins() {
 		pin.Configure(m.PinConfig{Mode: m.PinOutput})
 		pin.High()
 	}
+	for _, pin := range ledPins {
+		if pin != m.NoPin {
+			pin.Configure(m.PinConfig{Mode: m.PinOutput})
+			pin.Low()
+		}
+	}
+	connPin.Configure(m.PinConfig{Mode: m.PinOutput})
+	connPin.Low()
+}
+
+// setIndicators turns the lock LEDs on or off to match the host
+func setIndicators(leds keyboard.LED) {
+	for i, pin := range ledPins {
+		if pin != m.NoPin {
+			pin.Set(leds.IsOn(keyboard.LED(1 << i)))
+		}
+	}
 }
 
 // configurePortExpanders sets up the IO expanders that read the columns
@@ -111,27 +168,245 @@ func readRow(rowIndex uint8) keyboard.Row {
 	return keyboard.Row(^b)
 }
 
+// BluefruitLEHost sends reports with the HID AT commands of the Bluefruit LE
+// firmware.  It does not implement keyboard.LEDHost: the stock firmware keeps
+// the HID output report to itself and has no AT command to read it.
+//
+// Reports are dropped while no central is connected, except for the last
+// keyboard report, which is sent when one connects so that keys held at the
+// time are not lost.
 type BluefruitLEHost struct {
 	spifriend *ble.SPIFriend
+	conn      *ble.Connection
+	profiles  *ble.Profiles
+	buttons   keyboard.MouseButton
+	report    keyboard.Report
+	pending   bool
+
+	layerChar *ble.Characteristic
+	lockChar  *ble.Characteristic
+	layer     uint8
+	leds      keyboard.LED
+
+	flashes uint8
+	flash   timer.Timer
 }
 
-func (host *BluefruitLEHost) Init() {
-	host.spifriend.SendAT("AT+GAPDEVNAME=TinyGo Model M Keyboard")
-	host.spifriend.SendAT("AT+BLEKEYBOARDEN=1")
-	host.spifriend.SendAT("ATZ")
+func (host *BluefruitLEHost) Init() error {
+	if err := host.spifriend.SetDeviceName("TinyGo Model M Keyboard"); err != nil {
+		return err
+	}
+	if err := host.spifriend.SetKeyboardEnabled(true); err != nil {
+		return err
+	}
+	if err := host.spifriend.SetBatteryEnabled(batteryPin != m.NoPin); err != nil {
+		return err
+	}
+	if err := host.addStatusService(); err != nil {
+		return err
+	}
+	return host.spifriend.ATZ()
 }
 
-func (host *BluefruitLEHost) Send(rpt *keyboard.Report) {
-	cmd := fmt.Sprintf(
-		"AT+BLEKEYBOARDCODE=%02X-%02X-%02X-%02X-%02X-%02X-%02X-%02X",
-		rpt[0], rpt[1], rpt[2], rpt[3], rpt[4], rpt[5], rpt[6], rpt[7],
-	)
-	debug("--> %s\r\n", cmd)
-	rsp, err := host.spifriend.SendAT(cmd)
+// statusService is a custom GATT service that exposes the state of the
+// keyboard to a configuration app
+var statusService = ble.UUID128("8E-C9-00-01-2B-5A-4E-1B-9B-4F-6D-0C-6E-3C-5A-71")
+
+// addStatusService registers the status service, with characteristics for
+// the active layer, the keymap version and the lock LEDs
+func (host *BluefruitLEHost) addStatusService() (err error) {
+	if err = host.spifriend.ClearGATT(); err != nil {
+		return err
+	}
+	svc, err := host.spifriend.AddService(statusService)
 	if err != nil {
-		debug("<-- (err) %s\r\n", err.Error())
+		return err
+	}
+	host.layerChar, err = svc.AddCharacteristic(ble.Characteristic{
+		UUID:        ble.UUID128("8E-C9-00-02-2B-5A-4E-1B-9B-4F-6D-0C-6E-3C-5A-71"),
+		Properties:  ble.PropRead | ble.PropNotify,
+		MinLen:      1,
+		MaxLen:      1,
+		Description: "Active layer",
+	}, []byte{0})
+	if err != nil {
+		return err
+	}
+	_, err = svc.AddCharacteristic(ble.Characteristic{
+		UUID:        ble.UUID128("8E-C9-00-03-2B-5A-4E-1B-9B-4F-6D-0C-6E-3C-5A-71"),
+		Properties:  ble.PropRead,
+		MinLen:      2,
+		MaxLen:      2,
+		Description: "Keymap version",
+	}, keymapVersion[:])
+	if err != nil {
+		return err
+	}
+	host.lockChar, err = svc.AddCharacteristic(ble.Characteristic{
+		UUID:        ble.UUID128("8E-C9-00-04-2B-5A-4E-1B-9B-4F-6D-0C-6E-3C-5A-71"),
+		Properties:  ble.PropRead | ble.PropNotify,
+		MinLen:      1,
+		MaxLen:      1,
+		Description: "Lock state",
+	}, []byte{0})
+	return err
+}
+
+// SetStatus updates the characteristics of the status service when the
+// active layer or the lock LEDs change
+func (host *BluefruitLEHost) SetStatus(layer uint8, leds keyboard.LED) {
+	if host.layerChar == nil || !host.conn.Connected() {
+		return
+	}
+	if layer != host.layer {
+		if err := host.layerChar.Write([]byte{layer}); err != nil {
+			debug("layer status failed: %s\r\n", err.Error())
+			return
+		}
+		host.layer = layer
+	}
+	if leds != host.leds {
+		if err := host.lockChar.Write([]byte{byte(leds)}); err != nil {
+			debug("lock status failed: %s\r\n", err.Error())
+			return
+		}
+		host.leds = leds
+	}
+}
+
+// Task reads any alerts from the module, tracks the connection state and sends
+// the held keyboard report once a central has connected
+func (host *BluefruitLEHost) Task() {
+	if err := host.spifriend.Poll(); err != nil {
+		debug("poll failed: %s\r\n", err.Error())
+	}
+	if err := host.conn.Task(); err != nil {
+		debug("connection poll failed: %s\r\n", err.Error())
+	}
+	if host.pending && host.conn.Connected() {
+		host.pending = false
+		host.sendReport(&host.report)
+	}
+	host.flashTask()
+}
+
+// connected checks that the central belongs to the active profile before
+// turning on the connection indicator
+func (host *BluefruitLEHost) connected() {
+	debug("bluefruit connected\r\n")
+	if err := host.profiles.Check(); err != nil {
+		debug("profile check failed: %s\r\n", err.Error())
+	}
+	connPin.High()
+}
+
+func (host *BluefruitLEHost) disconnected() {
+	debug("bluefruit disconnected\r\n")
+	connPin.Low()
+}
+
+func (host *BluefruitLEHost) setBatteryLevel(percent uint8) {
+	debug("battery: %d%%\r\n", percent)
+	if err := host.spifriend.SetBatteryLevel(percent); err != nil {
+		debug("battery level failed: %s\r\n", err.Error())
+	}
+}
+
+const (
+	lowBatteryFlashes = 3
+	lowBatteryFlash   = 100 * time.Millisecond
+)
+
+// lowBattery starts flashing the connection indicator as a warning; Task
+// does the flashing so that the main loop is not held up
+func (host *BluefruitLEHost) lowBattery(low bool) {
+	debug("battery low: %t\r\n", low)
+	if !low {
+		return
+	}
+	host.flashes = 2 * lowBatteryFlashes
+	host.flash = timer.New(clock, 0)
+}
+
+// flashTask inverts the connection indicator on every other step of a
+// low battery warning, and puts it back on the steps in between
+func (host *BluefruitLEHost) flashTask() {
+	if host.flashes == 0 || !host.flash.Expired() {
+		return
+	}
+	host.flashes--
+	connPin.Set(host.conn.Connected() != (host.flashes%2 == 1))
+	host.flash = timer.New(clock, lowBatteryFlash)
+}
+
+// SelectProfile switches to another bonded central; reports held for the
+// previous one are discarded
+func (host *BluefruitLEHost) SelectProfile(profile uint8) {
+	host.pending = false
+	if err := host.profiles.Select(profile); err != nil {
+		debug("profile select failed: %s\r\n", err.Error())
+	}
+}
+
+// ClearProfiles deletes all bonds so that new centrals can be paired
+func (host *BluefruitLEHost) ClearProfiles() {
+	host.pending = false
+	if err := host.profiles.Clear(); err != nil {
+		debug("profile clear failed: %s\r\n", err.Error())
+	}
+}
+
+func (host *BluefruitLEHost) Send(rpt *keyboard.Report) {
+	host.report = *rpt
+	if !host.conn.Connected() {
+		host.pending = true
+		return
+	}
+	host.pending = false
+	host.sendReport(rpt)
+}
+
+// sendReport does not allocate, so that typing does not put pressure on the
+// garbage collector
+func (host *BluefruitLEHost) sendReport(rpt *keyboard.Report) {
+	if err := host.spifriend.KeyboardCode((*[8]byte)(rpt)); err != nil {
+		debug("keyboard code failed: %s\r\n", err.Error())
+	}
+}
+
+// SendConsumer uses AT+BLEHIDCONTROLKEY with the raw usage ID; the module
+// sends both the press and the release, so empty reports are not forwarded.
+func (host *BluefruitLEHost) SendConsumer(usage keyboard.ConsumerUsage) {
+	if usage == keyboard.ConsumerNone || !host.conn.Connected() {
+		return
+	}
+	if err := host.spifriend.ControlKey(uint16(usage)); err != nil {
+		debug("control key failed: %s\r\n", err.Error())
+	}
+}
+
+// SendSystem is a no-op; the Bluefruit firmware has no system control report
+func (host *BluefruitLEHost) SendSystem(usage keyboard.SystemUsage) {
+	debug("system control not supported: %04X\r\n", uint16(usage))
+}
+
+// SendMouse uses AT+BLEHIDMOUSEBUTTON when the button state has changed and
+// AT+BLEHIDMOUSEMOVE for cursor and wheel movement
+func (host *BluefruitLEHost) SendMouse(rpt *keyboard.MouseReport) {
+	if !host.conn.Connected() {
+		return
+	}
+	if rpt.Buttons != host.buttons {
+		host.buttons = rpt.Buttons
+		if err := host.spifriend.MouseButton(uint8(rpt.Buttons)); err != nil {
+			debug("mouse button failed: %s\r\n", err.Error())
+		}
+	}
+	if rpt.IsMoving() {
+		if err := host.spifriend.MouseMove(rpt.X, rpt.Y, rpt.V, rpt.H); err != nil {
+			debug("mouse move failed: %s\r\n", err.Error())
+		}
 	}
-	debug("<-- %s\r\n", string(rsp))
 }
 
 type EZKeyHost struct {
@@ -143,6 +418,50 @@ func (host *EZKeyHost) Send(report *keyboard.Report) {
 	host.hid.Send(&rpt)
 }
 
+func (host *EZKeyHost) SendConsumer(usage keyboard.ConsumerUsage) {
+	var key ezkey.ConsumerKey
+	switch usage {
+	case keyboard.ConsumerWWWHome:
+		key = ezkey.ConsKeyHome
+	case keyboard.ConsumerWWWSearch:
+		key = ezkey.ConsKeySearch
+	case keyboard.ConsumerVolumeUp:
+		key = ezkey.ConsKeyVolUp
+	case keyboard.ConsumerVolumeDown:
+		key = ezkey.ConsKeyVolDown
+	case keyboard.ConsumerPlayPause:
+		key = ezkey.ConsKeyPlayPause
+	case keyboard.ConsumerFastForward:
+		key = ezkey.ConsKeyFastFwd
+	case keyboard.ConsumerRewind:
+		key = ezkey.ConsKeyRewind
+	case keyboard.ConsumerNextTrack:
+		key = ezkey.ConsKeyNextTrack
+	case keyboard.ConsumerPrevTrack:
+		key = ezkey.ConsKeyPrevTrack
+	case keyboard.ConsumerStop:
+		key = ezkey.ConsKeyStop
+	case keyboard.ConsumerNone:
+	default:
+		debug("consumer usage not supported: %04X\r\n", uint16(usage))
+		return
+	}
+	var rpt ezkey.Report
+	host.hid.Send(rpt.Consumer(key))
+}
+
+// SendSystem is a no-op; the EZ-Key has no system control report
+func (host *EZKeyHost) SendSystem(usage keyboard.SystemUsage) {
+	debug("system control not supported: %04X\r\n", uint16(usage))
+}
+
+// SendMouse sends the buttons and cursor movement; the EZ-Key mouse report
+// does not have the scroll wheels
+func (host *EZKeyHost) SendMouse(report *keyboard.MouseReport) {
+	var rpt ezkey.Report
+	host.hid.Send(rpt.Mouse(ezkey.MouseButton(report.Buttons), report.X, report.Y))
+}
+
 //go:inline
 func debug(format string, args ...interface{}) {
 	if _debug {
@@ -151,5 +470,5 @@ func debug(format string, args ...interface{}) {
 }
 
 func delayMicros(usecs uint32) {
-	timer.Wait(time.Duration(usecs) * time.Microsecond)
+	clock.Sleep(time.Duration(usecs) * time.Microsecond)
 }
@@ -0,0 +1,129 @@
+// Command keymapgen compiles a keymap in the text format of the keymap
+// package into Go source for the Model M, e.g. with a directive such as
+//
+//	//go:generate go run github.com/bgould/tinygo-model-m/modelm/cmd/keymapgen -o keymap_gen.go layout.keymap
+//
+// The generated function returns the layers as []keyboard.Keymap, laid out in
+// the same grid as the text file.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bgould/tinygo-model-m/keyboard/keymap"
+	"github.com/bgould/tinygo-model-m/modelm"
+)
+
+var (
+	output   = flag.String("o", "", "output file (default stdout)")
+	pkgName  = flag.String("pkg", os.Getenv("GOPACKAGE"), "package name (default $GOPACKAGE or main)")
+	funcName = flag.String("func", "Layers", "name of the generated function")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: keymapgen [flags] file.keymap\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "keymapgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	layers, err := keymap.Parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(layers) == 0 {
+		return fmt.Errorf("%s: no layers", path)
+	}
+	for i := range layers {
+		if _, err := modelm.ANSI101Layer(&layers[i]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	src, err := generate(filepath.Base(path), layers)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*output, src, 0644)
+}
+
+func generate(source string, layers []keymap.Layer) ([]byte, error) {
+	pkg := *pkgName
+	if pkg == "" {
+		pkg = "main"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by keymapgen from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import (\n")
+	fmt.Fprintf(&buf, "\t%q\n", "github.com/bgould/tinygo-model-m/keyboard")
+	fmt.Fprintf(&buf, "\t%q\n\n", "github.com/bgould/tinygo-model-m/modelm")
+	fmt.Fprintf(&buf, "\t. %q\n", "github.com/bgould/tinygo-model-m/keyboard/keycodes")
+	fmt.Fprintf(&buf, ")\n\n")
+	fmt.Fprintf(&buf, "func %s() []keyboard.Keymap {\n", *funcName)
+	fmt.Fprintf(&buf, "\treturn []keyboard.Keymap{\n")
+	for i := range layers {
+		layer := &layers[i]
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if layer.Name != "" {
+			fmt.Fprintf(&buf, "\t\t// layer %s\n", layer.Name)
+		}
+		buf.WriteString("\t\tmodelm.ANSI101Keymap(\n")
+		writeGrid(&buf, layer.Keys)
+		buf.WriteString("\t\t),\n")
+	}
+	fmt.Fprintf(&buf, "\t}\n}\n")
+	// gofmt would collapse the grid, so only check that the source is valid
+	if _, err := parser.ParseFile(token.NewFileSet(), "", buf.Bytes(), 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeGrid writes the keys with the same line breaks as the text file, and
+// each name at its original column if there is room
+func writeGrid(buf *bytes.Buffer, keys []keymap.Key) {
+	var line strings.Builder
+	for i, key := range keys {
+		if i > 0 && key.Line != keys[i-1].Line {
+			fmt.Fprintf(buf, "\t\t\t%s\n", strings.TrimRight(line.String(), " "))
+			if key.Line > keys[i-1].Line+1 {
+				buf.WriteString("\n")
+			}
+			line.Reset()
+		}
+		if pad := key.Col - line.Len(); pad > 0 {
+			line.WriteString(strings.Repeat(" ", pad))
+		}
+		line.WriteString(key.Name)
+		line.WriteString(",")
+	}
+	fmt.Fprintf(buf, "\t\t\t%s\n", strings.TrimRight(line.String(), " "))
+}
@@ -6,6 +6,11 @@ import (
 	. "github.com/bgould/tinygo-model-m/keyboard/keycodes"
 )
 
+const (
+	MatrixRows = 8
+	MatrixCols = 16
+)
+
 func ANSI101DefaultLayer() keyboard.Keymap {
 	return ANSI101Keymap(
 
@@ -31,7 +36,7 @@ func ANSI101Keymap(
   K50, K7F,                   K06,                            K0F, K10,   K0E, K0B, K0C,   K7C,      K7D       Keycode,
 
 ) keyboard.Keymap {
-	return keyboard.Keymap([8][16]Keycode{
+	return keyboard.Keymap([][]Keycode{
 		/*       0x0  0x1  0x2  0x3  0x4  0x5  0x6  0x7  0x8  0x9  0xA  0xB  0xC  0xD  0xE  0xF */
 		/****************************************************************************************/
 		/* 0 */ {0x0, 0x0, 0x0, 0x0, 0x0, K05, K06, K07, 0x0, 0x0, K0A, K0B, K0C, K0D, K0E, K0F},
@@ -0,0 +1,45 @@
+package modelm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bgould/tinygo-model-m/keyboard"
+	"github.com/bgould/tinygo-model-m/keyboard/keymap"
+)
+
+// ANSI101Keys is the number of keys in each layer passed to ANSI101Keymap
+const ANSI101Keys = 101
+
+// ParseANSI101 reads a keymap in the text format of the keymap package, with
+// the keys of each layer in the same order as the parameters of ANSI101Keymap
+func ParseANSI101(r io.Reader) ([]keyboard.Keymap, error) {
+	layers, err := keymap.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	keymaps := make([]keyboard.Keymap, len(layers))
+	for i := range layers {
+		if keymaps[i], err = ANSI101Layer(&layers[i]); err != nil {
+			return nil, err
+		}
+	}
+	return keymaps, nil
+}
+
+// ANSI101Layer maps a parsed layer through ANSI101Keymap
+func ANSI101Layer(layer *keymap.Layer) (keyboard.Keymap, error) {
+	if len(layer.Keys) != ANSI101Keys {
+		return nil, fmt.Errorf("keymap: line %d: layer %q has %d keys, expected %d",
+			layer.Line, layer.Name, len(layer.Keys), ANSI101Keys)
+	}
+	k := layer.Keycodes()
+	return ANSI101Keymap(
+		k[0], k[1], k[2], k[3], k[4], k[5], k[6], k[7], k[8], k[9], k[10], k[11], k[12], k[13], k[14], k[15],
+		k[16], k[17], k[18], k[19], k[20], k[21], k[22], k[23], k[24], k[25], k[26], k[27], k[28], k[29], k[30], k[31], k[32], k[33], k[34], k[35], k[36],
+		k[37], k[38], k[39], k[40], k[41], k[42], k[43], k[44], k[45], k[46], k[47], k[48], k[49], k[50], k[51], k[52], k[53], k[54], k[55], k[56], k[57],
+		k[58], k[59], k[60], k[61], k[62], k[63], k[64], k[65], k[66], k[67], k[68], k[69], k[70], k[71], k[72], k[73],
+		k[74], k[75], k[76], k[77], k[78], k[79], k[80], k[81], k[82], k[83], k[84], k[85], k[86], k[87], k[88], k[89], k[90],
+		k[91], k[92], k[93], k[94], k[95], k[96], k[97], k[98], k[99], k[100],
+	), nil
+}
@@ -0,0 +1,81 @@
+package modelm_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bgould/tinygo-model-m/modelm"
+)
+
+func TestParseANSI101(t *testing.T) {
+	f, err := os.Open("ansi101.keymap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	keymaps, err := modelm.ParseANSI101(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keymaps) != 1 {
+		t.Fatalf("got %d layers, want 1", len(keymaps))
+	}
+	want := modelm.ANSI101DefaultLayer()
+	got := keymaps[0]
+	if len(got) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("row %d: got %d columns, want %d", i, len(got[i]), len(want[i]))
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Errorf("row %d, col %d: got %s, want %s", i, j, got[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestParseANSI101Errors(t *testing.T) {
+	b, err := os.ReadFile("ansi101.keymap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(b)
+	tests := []struct {
+		name string
+		text string
+		err  string
+	}{
+		{
+			name: "unknown keycode",
+			text: strings.Replace(text, "PSCR", "PRTSC", 1),
+			err:  `unknown keycode "PRTSC"`,
+		},
+		{
+			name: "missing key",
+			text: strings.Replace(text, "PSCR,", "", 1),
+			err:  `layer "0" has 100 keys, expected 101`,
+		},
+		{
+			name: "extra key",
+			text: strings.Replace(text, "PSCR,", "PSCR,PSCR,", 1),
+			err:  `layer "0" has 102 keys, expected 101`,
+		},
+		{
+			name: "missing layer line",
+			text: strings.Replace(text, "layer 0", "", 1),
+			err:  `keycode "ESC" before the first layer`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := modelm.ParseANSI101(strings.NewReader(tt.text))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -2,30 +2,80 @@ package timer
 
 import "time"
 
-func Wait(duration time.Duration) {
-	//start := time.Now().UnixNano()
-	//for stop := start + int64(duration); time.Now().UnixNano() < stop; {
-	//}
-	New(duration).WaitUntilExpired()
+// Clock is a monotonic source of time that can also block for a duration.
+// System is the real clock; Fake can be used to drive code that depends on
+// timing without actually waiting.
+type Clock interface {
+	Now() time.Duration
+	Sleep(duration time.Duration)
+}
+
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Duration {
+	return time.Duration(time.Now().UnixNano())
+}
+
+func (systemClock) Sleep(duration time.Duration) {
+	time.Sleep(duration)
+}
+
+// Millis returns the current time of the clock in milliseconds, wrapping
+// around after about 49 days.
+func Millis(clock Clock) uint32 {
+	return uint32(clock.Now() / time.Millisecond)
+}
+
+// Fake is a clock that only moves when it is advanced manually or when Sleep
+// is called, which returns immediately after advancing the clock.
+type Fake struct {
+	now time.Duration
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Now() time.Duration {
+	return f.now
+}
+
+func (f *Fake) Sleep(duration time.Duration) {
+	f.Advance(duration)
+}
+
+func (f *Fake) Advance(duration time.Duration) {
+	if duration > 0 {
+		f.now += duration
+	}
+}
+
+func (f *Fake) Set(now time.Duration) {
+	f.now = now
 }
 
 type Timer struct {
-	start    int64
-	interval int64
+	clock    Clock
+	start    time.Duration
+	interval time.Duration
 }
 
-func New(interval time.Duration) Timer {
+func New(clock Clock, interval time.Duration) Timer {
 	return Timer{
-		start:    time.Now().UnixNano(),
-		interval: int64(interval),
+		clock:    clock,
+		start:    clock.Now(),
+		interval: interval,
 	}
 }
 
 func (t Timer) Expired() bool {
-	return time.Now().UnixNano() > (t.start + t.interval)
+	return t.clock.Now() >= (t.start + t.interval)
 }
 
 func (t Timer) WaitUntilExpired() {
-	for !t.Expired() {
+	if remaining := t.start + t.interval - t.clock.Now(); remaining > 0 {
+		t.clock.Sleep(remaining)
 	}
 }
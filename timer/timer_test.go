@@ -0,0 +1,45 @@
+package timer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bgould/tinygo-model-m/timer"
+)
+
+func TestFake(t *testing.T) {
+	clock := timer.NewFake()
+	clock.Advance(3 * time.Millisecond)
+	clock.Sleep(2 * time.Millisecond)
+	clock.Advance(-time.Second)
+	if got, want := clock.Now(), 5*time.Millisecond; got != want {
+		t.Fatalf("now = %v, want %v", got, want)
+	}
+	clock.Set(time.Second + 500*time.Microsecond)
+	if got := timer.Millis(clock); got != 1000 {
+		t.Fatalf("millis = %d, want 1000", got)
+	}
+}
+
+func TestTimer(t *testing.T) {
+	clock := timer.NewFake()
+	clock.Set(time.Second)
+	tm := timer.New(clock, 10*time.Millisecond)
+	if tm.Expired() {
+		t.Fatal("expired as soon as it started")
+	}
+	clock.Advance(9 * time.Millisecond)
+	if tm.Expired() {
+		t.Fatal("expired early")
+	}
+	tm.WaitUntilExpired()
+	if !tm.Expired() || clock.Now() != time.Second+10*time.Millisecond {
+		t.Fatalf("waited until %v", clock.Now())
+	}
+	// waiting on an expired timer does not sleep
+	clock.Advance(time.Millisecond)
+	tm.WaitUntilExpired()
+	if clock.Now() != time.Second+11*time.Millisecond {
+		t.Fatalf("waited until %v after expiry", clock.Now())
+	}
+}